@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+
+	font_service "GoogleFontsPluginApi/font-service"
+	"GoogleFontsPluginApi/logger"
+)
+
+// ApiError is an error with everything needed to respond to the client. Code
+// is a stable machine readable identifier, Message is meant for humans.
+type ApiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ApiError) Error() string { return e.Message }
+
+func NewApiError(status int, code string, format string, args ...any) *ApiError {
+	return &ApiError{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func NewBadRequestError(code string, format string, args ...any) *ApiError {
+	return NewApiError(fiber.StatusBadRequest, code, format, args...)
+}
+
+func NewNotFoundError(code string, format string, args ...any) *ApiError {
+	return NewApiError(fiber.StatusNotFound, code, format, args...)
+}
+
+var fontErrorStatuses = map[string]int{
+	font_service.ErrUpstreamUnavailable.Code: fiber.StatusBadGateway,
+	font_service.ErrFontNotFound.Code:        fiber.StatusNotFound,
+	font_service.ErrVariantNotFound.Code:     fiber.StatusNotFound,
+	font_service.ErrInvalidFontData.Code:     fiber.StatusBadGateway,
+	font_service.ErrInvalidOptions.Code:      fiber.StatusBadRequest,
+}
+
+// ToApiError converts any error returned by a handler into an ApiError. Only
+// the message of font errors is sent, the errors they wrap can contain
+// internal details. ErrorHandler logs server errors in full.
+func ToApiError(err error) *ApiError {
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fontErr *font_service.FontError
+	if errors.As(err, &fontErr) {
+		status, found := fontErrorStatuses[fontErr.Code]
+		if !found {
+			status = fiber.StatusInternalServerError
+		}
+		return NewApiError(status, fontErr.Code, "%s", fontErr.Message)
+	}
+
+	// Errors created by fiber itself, e.g. unknown routes or methods
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return NewApiError(fiberErr.Code, statusErrorCode(fiberErr.Code), "%s", fiberErr.Message)
+	}
+
+	return NewApiError(fiber.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+// statusErrorCode turns a status into an error code, 404 -> "not_found"
+func statusErrorCode(status int) string {
+	code := strings.ToLower(http.StatusText(status))
+	code = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(code)
+	if code == "" {
+		return "error"
+	}
+	return code
+}
+
+// ErrorHandler responds to every error with a json body in the same format:
+//
+//	{"error": {"status": 404, "code": "font_not_found", "message": "...", "requestId": "..."}}
+func ErrorHandler(c fiber.Ctx, err error) error {
+	apiErr := ToApiError(err)
+	rid := requestid.FromContext(c)
+
+	if apiErr.Status >= fiber.StatusInternalServerError {
+		logger.Error("[%s] %s %s: %v", rid, c.Method(), c.Path(), err)
+	}
+
+	return c.Status(apiErr.Status).JSON(map[string]any{
+		"error": map[string]any{
+			"status":    apiErr.Status,
+			"code":      apiErr.Code,
+			"message":   apiErr.Message,
+			"requestId": rid,
+		},
+	})
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+// LRUCache is a size bounded cache, once the total size of its items goes
+// over maxSize the least recently used items are evicted.
+type LRUCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	items   map[K]*list.Element // The map storing the list elements of the items.
+	order   *list.List          // Most recently used items at the front.
+	size    int64
+	maxSize int64
+	sizeOf  func(V) int64
+	onEvict func(K, V)
+}
+
+// NewLRU creates a new LRUCache, sizeOf returns the size of a value in the
+// same unit as maxSize. A maxSize <= 0 means the cache has no size limit.
+func NewLRU[K comparable, V any](maxSize int64, sizeOf func(V) int64) *LRUCache[K, V] {
+	return &LRUCache[K, V]{
+		items:   make(map[K]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+		sizeOf:  sizeOf,
+	}
+}
+
+// OnEvict sets a function called with every item evicted to make room for
+// new ones. It is called without holding the cache's lock.
+func (c *LRUCache[K, V]) OnEvict(f func(K, V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = f
+}
+
+// Get retrieves the value associated with the given key and marks it as the
+// most recently used.
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.items[key]
+	if !found {
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*lruItem[K, V]).value, true
+}
+
+// Set adds or replaces the item with the given key, evicting the least
+// recently used items when the cache goes over its size limit. Items larger
+// than the whole cache are not stored, Set reports whether the item was stored.
+func (c *LRUCache[K, V]) Set(key K, value V) bool {
+	size := c.sizeOf(value)
+
+	c.mu.Lock()
+	if c.maxSize > 0 && size > c.maxSize {
+		c.mu.Unlock()
+		return false
+	}
+
+	if element, found := c.items[key]; found {
+		c.removeElement(element)
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem[K, V]{key: key, value: value, size: size})
+	c.size += size
+
+	var evicted []*lruItem[K, V]
+	for c.maxSize > 0 && c.size > c.maxSize {
+		oldest := c.order.Back()
+		evicted = append(evicted, oldest.Value.(*lruItem[K, V]))
+		c.removeElement(oldest)
+	}
+
+	onEvict := c.onEvict
+	c.mu.Unlock()
+
+	if onEvict != nil {
+		for _, item := range evicted {
+			onEvict(item.key, item.value)
+		}
+	}
+
+	return true
+}
+
+// Remove removes the item with the specified key from the cache.
+func (c *LRUCache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.items[key]; found {
+		c.removeElement(element)
+	}
+}
+
+func (c *LRUCache[K, V]) removeElement(element *list.Element) {
+	item := c.order.Remove(element).(*lruItem[K, V])
+	delete(c.items, item.key)
+	c.size -= item.size
+}
+
+func (c *LRUCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// MaxSize returns the size limit of the cache, <= 0 when it has none
+func (c *LRUCache[K, V]) MaxSize() int64 {
+	return c.maxSize
+}
+
+// Size returns the total size of the items in the cache
+func (c *LRUCache[K, V]) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	type op struct {
+		get  bool
+		key  string
+		size int64
+	}
+	set := func(key string, size int64) op { return op{key: key, size: size} }
+	get := func(key string) op { return op{get: true, key: key} }
+
+	tests := []struct {
+		name        string
+		maxSize     int64
+		ops         []op
+		wantKeys    []string
+		wantEvicted []string
+		wantSize    int64
+	}{
+		{
+			name:     "fits",
+			maxSize:  10,
+			ops:      []op{set("a", 3), set("b", 3), set("c", 4)},
+			wantKeys: []string{"a", "b", "c"},
+			wantSize: 10,
+		},
+		{
+			name:        "evicts the oldest",
+			maxSize:     10,
+			ops:         []op{set("a", 4), set("b", 4), set("c", 4)},
+			wantKeys:    []string{"b", "c"},
+			wantEvicted: []string{"a"},
+			wantSize:    8,
+		},
+		{
+			name:        "get marks as recently used",
+			maxSize:     10,
+			ops:         []op{set("a", 4), set("b", 4), get("a"), set("c", 4)},
+			wantKeys:    []string{"a", "c"},
+			wantEvicted: []string{"b"},
+			wantSize:    8,
+		},
+		{
+			name:        "evicts as many as needed",
+			maxSize:     10,
+			ops:         []op{set("a", 3), set("b", 3), set("c", 3), set("d", 9)},
+			wantKeys:    []string{"d"},
+			wantEvicted: []string{"a", "b", "c"},
+			wantSize:    9,
+		},
+		{
+			name:     "replacing updates the size",
+			maxSize:  10,
+			ops:      []op{set("a", 4), set("b", 4), set("a", 6)},
+			wantKeys: []string{"a", "b"},
+			wantSize: 10,
+		},
+		{
+			name:     "items larger than the cache are not stored",
+			maxSize:  10,
+			ops:      []op{set("a", 4), set("b", 11)},
+			wantKeys: []string{"a"},
+			wantSize: 4,
+		},
+		{
+			name:     "no size limit",
+			maxSize:  0,
+			ops:      []op{set("a", 100), set("b", 100)},
+			wantKeys: []string{"a", "b"},
+			wantSize: 200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU[string, int64](tt.maxSize, func(size int64) int64 { return size })
+
+			var evicted []string
+			c.OnEvict(func(key string, _ int64) { evicted = append(evicted, key) })
+
+			for _, o := range tt.ops {
+				if o.get {
+					c.Get(o.key)
+					continue
+				}
+
+				stored := c.Set(o.key, o.size)
+				if wantStored := tt.maxSize <= 0 || o.size <= tt.maxSize; stored != wantStored {
+					t.Errorf("Set(%q, %d) = %v, want %v", o.key, o.size, stored, wantStored)
+				}
+			}
+
+			var keys []string
+			for _, key := range []string{"a", "b", "c", "d"} {
+				if _, found := c.Get(key); found {
+					keys = append(keys, key)
+				}
+			}
+
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if !slices.Equal(evicted, tt.wantEvicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.wantEvicted)
+			}
+			if c.Size() != tt.wantSize {
+				t.Errorf("size = %d, want %d", c.Size(), tt.wantSize)
+			}
+			if c.Len() != len(tt.wantKeys) {
+				t.Errorf("len = %d, want %d", c.Len(), len(tt.wantKeys))
+			}
+		})
+	}
+}
@@ -15,9 +15,19 @@ type item[V any] struct {
 	expiry time.Time
 }
 
-// isExpired checks if the cache item has expired.
+// isExpired checks if the cache item has expired. Items without an expiry
+// time never expire.
 func (i item[V]) isExpired() bool {
-	return time.Now().After(i.expiry)
+	return !i.expiry.IsZero() && time.Now().After(i.expiry)
+}
+
+// expiryFor returns the expiry time for an item stored now with the given
+// ttl, a ttl <= 0 means the item never expires.
+func expiryFor(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
 }
 
 // TTLCache is a generic cache implementation with support for time-to-live
@@ -29,7 +39,7 @@ type TTLCache[K comparable, V any] struct {
 }
 
 // NewTTL creates a new TTLCache instance and starts a goroutine to periodically
-// remove expired items every 5 seconds.
+// remove expired items every 5 seconds. A ttl <= 0 disables expiry.
 func NewTTL[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
 	c := &TTLCache[K, V]{
 		items:      make(map[K]item[V]),
@@ -100,7 +110,7 @@ func (c *TTLCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
 
 	c.items[key] = item[V]{
 		value:  value,
-		expiry: time.Now().Add(ttl),
+		expiry: expiryFor(ttl),
 	}
 }
 
@@ -158,6 +168,24 @@ func (c *TTLCache[K, V]) Pop(key K) (V, bool) {
 	return item.value, true
 }
 
+// Replace swaps the entire contents of the cache for the given values in a
+// single step, so readers never observe a partially populated cache.
+func (c *TTLCache[K, V]) Replace(values map[K]V) {
+	items := make(map[K]item[V], len(values))
+	expiry := expiryFor(c.defaultTTL)
+	for key, value := range values {
+		items[key] = item[V]{
+			value:  value,
+			expiry: expiry,
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = items
+}
+
 func (c *TTLCache[K, V]) Len() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
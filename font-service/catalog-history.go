@@ -0,0 +1,215 @@
+package font_service
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"GoogleFontsPluginApi/utils"
+)
+
+// Snapshot file names are timestamps, so sorting them by name sorts them by time
+const catalogSnapshotTimeFormat = "20060102T150405.000000000Z"
+
+const defaultCatalogHistoryMaxSnapshots = 365
+
+type CatalogSnapshotFamily struct {
+	Name         string `json:"name"`
+	Version      string `json:"version,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// CatalogSnapshot is the state of a provider's catalog at a point in time,
+// stored under GetProviderPath(id, "history")
+type CatalogSnapshot struct {
+	CreatedAt time.Time               `json:"createdAt"`
+	Families  []CatalogSnapshotFamily `json:"families"`
+}
+
+func NewCatalogSnapshot(createdAt time.Time, families []FontFamilyData) CatalogSnapshot {
+	snapshot := CatalogSnapshot{
+		CreatedAt: createdAt.UTC(),
+		Families:  make([]CatalogSnapshotFamily, 0, len(families)),
+	}
+
+	for _, f := range families {
+		snapshot.Families = append(snapshot.Families, CatalogSnapshotFamily{
+			Name:         f.Name,
+			Version:      f.Version,
+			LastModified: f.LastModified,
+		})
+	}
+
+	slices.SortFunc(snapshot.Families, func(a, b CatalogSnapshotFamily) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return snapshot
+}
+
+func (s CatalogSnapshot) familyData() []FontFamilyData {
+	data := make([]FontFamilyData, len(s.Families))
+	for i, f := range s.Families {
+		data[i] = FontFamilyData{
+			Name:         f.Name,
+			Version:      f.Version,
+			LastModified: f.LastModified,
+		}
+	}
+	return data
+}
+
+// CatalogChanges is the net change of a catalog between two snapshots
+type CatalogChanges struct {
+	Since time.Time `json:"since"`
+	// The snapshot the changes were computed from, the newest one taken at or before Since
+	BaselineAt time.Time `json:"baselineAt"`
+	// When the most recent snapshot was taken
+	LatestAt time.Time             `json:"latestAt"`
+	Added    []string              `json:"added"`
+	Removed  []string              `json:"removed"`
+	Updated  []CatalogFamilyChange `json:"updated"`
+}
+
+func getCatalogHistoryPath(providerId string, subPaths ...string) string {
+	return GetProviderPath(providerId, append([]string{"history"}, subPaths...)...)
+}
+
+func saveCatalogSnapshot(providerId string, snapshot CatalogSnapshot) error {
+	name := snapshot.CreatedAt.UTC().Format(catalogSnapshotTimeFormat) + ".json"
+	return saveCacheData(getCatalogHistoryPath(providerId, name), snapshot)
+}
+
+// GetCatalogHistoryMaxSnapshots reads CATALOG_HISTORY_MAX_SNAPSHOTS, how many
+// snapshots are kept per provider, 0 keeps every snapshot
+func GetCatalogHistoryMaxSnapshots() int {
+	return utils.GetEnvInt("CATALOG_HISTORY_MAX_SNAPSHOTS", defaultCatalogHistoryMaxSnapshots)
+}
+
+// pruneCatalogSnapshots removes the oldest snapshots until at most maxSnapshots
+// are left, changes since a removed snapshot are compared against the oldest
+// one that's left
+func pruneCatalogSnapshots(providerId string, maxSnapshots int) error {
+	if maxSnapshots <= 0 {
+		return nil
+	}
+
+	times, err := listCatalogSnapshots(providerId)
+	if err != nil || len(times) <= maxSnapshots {
+		return err
+	}
+
+	for _, t := range times[:len(times)-maxSnapshots] {
+		name := t.UTC().Format(catalogSnapshotTimeFormat) + ".json"
+		if err := os.Remove(getCatalogHistoryPath(providerId, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listCatalogSnapshots returns the times of every stored snapshot, oldest first
+func listCatalogSnapshots(providerId string) ([]time.Time, error) {
+	entries, err := os.ReadDir(getCatalogHistoryPath(providerId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Time
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		t, err := time.Parse(catalogSnapshotTimeFormat, name)
+		if err != nil {
+			continue
+		}
+
+		times = append(times, t)
+	}
+
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+
+	return times, nil
+}
+
+func loadCatalogSnapshot(providerId string, createdAt time.Time) (CatalogSnapshot, error) {
+	var snapshot CatalogSnapshot
+
+	name := createdAt.UTC().Format(catalogSnapshotTimeFormat) + ".json"
+	if _, err := loadCacheData(getCatalogHistoryPath(providerId, name), &snapshot); err != nil {
+		return snapshot, err
+	}
+
+	return snapshot, nil
+}
+
+// ensureCatalogSnapshot stores the initial snapshot of a provider's catalog so
+// later refreshes have something to be compared against
+func ensureCatalogSnapshot(provider IFontProvider) error {
+	times, err := listCatalogSnapshots(provider.GetId())
+	if err != nil || len(times) > 0 {
+		return err
+	}
+
+	return saveCatalogSnapshot(provider.GetId(), NewCatalogSnapshot(time.Now(), provider.GetFontCache().All()))
+}
+
+// GetCatalogChangesSince compares the newest snapshot taken at or before since
+// with the latest snapshot. When since is older than the history, the oldest
+// snapshot we have is used instead.
+func GetCatalogChangesSince(providerId string, since time.Time) (*CatalogChanges, error) {
+	changes := &CatalogChanges{
+		Since:   since.UTC(),
+		Added:   []string{},
+		Removed: []string{},
+		Updated: []CatalogFamilyChange{},
+	}
+
+	times, err := listCatalogSnapshots(providerId)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return changes, nil
+	}
+
+	baselineAt := times[0]
+	for _, t := range times {
+		if t.After(since) {
+			break
+		}
+		baselineAt = t
+	}
+	latestAt := times[len(times)-1]
+
+	changes.BaselineAt = baselineAt
+	changes.LatestAt = latestAt
+
+	if baselineAt.Equal(latestAt) {
+		return changes, nil
+	}
+
+	baseline, err := loadCatalogSnapshot(providerId, baselineAt)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := loadCatalogSnapshot(providerId, latestAt)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffCatalogs(baseline.familyData(), latest.familyData())
+	changes.Added = diff.Added
+	changes.Removed = diff.Removed
+	changes.Updated = diff.Updated
+
+	return changes, nil
+}
@@ -0,0 +1,64 @@
+package font_service
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPruneCatalogSnapshots(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshotTimes := func(days ...int) []time.Time {
+		times := make([]time.Time, len(days))
+		for i, day := range days {
+			times[i] = start.AddDate(0, 0, day)
+		}
+		return times
+	}
+
+	tests := []struct {
+		name         string
+		snapshots    []time.Time
+		maxSnapshots int
+		want         []time.Time
+	}{
+		{name: "under the limit", snapshots: snapshotTimes(0, 1), maxSnapshots: 3, want: snapshotTimes(0, 1)},
+		{name: "at the limit", snapshots: snapshotTimes(0, 1, 2), maxSnapshots: 3, want: snapshotTimes(0, 1, 2)},
+		{name: "removes the oldest", snapshots: snapshotTimes(0, 1, 2, 3, 4), maxSnapshots: 2, want: snapshotTimes(3, 4)},
+		{name: "no limit", snapshots: snapshotTimes(0, 1, 2), maxSnapshots: 0, want: snapshotTimes(0, 1, 2)},
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerId := "provider-" + strconv.Itoa(i)
+			for _, createdAt := range tt.snapshots {
+				if err := saveCatalogSnapshot(providerId, NewCatalogSnapshot(createdAt, nil)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if err := pruneCatalogSnapshots(providerId, tt.maxSnapshots); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := listCatalogSnapshots(providerId)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.EqualFunc(got, tt.want, time.Time.Equal) {
+				t.Errorf("snapshots = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package font_service
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"GoogleFontsPluginApi/logger"
+	"GoogleFontsPluginApi/utils"
+)
+
+const defaultCatalogRefreshInterval = 24 * time.Hour
+
+type CatalogFamilyChange struct {
+	Name                 string `json:"name"`
+	PreviousVersion      string `json:"previousVersion,omitempty"`
+	Version              string `json:"version,omitempty"`
+	PreviousLastModified string `json:"previousLastModified,omitempty"`
+	LastModified         string `json:"lastModified,omitempty"`
+}
+
+// CatalogDiff describes what changed in a provider's catalog during a refresh
+type CatalogDiff struct {
+	RefreshedAt time.Time             `json:"refreshedAt"`
+	Added       []string              `json:"added"`
+	Removed     []string              `json:"removed"`
+	Updated     []CatalogFamilyChange `json:"updated"`
+}
+
+func (d *CatalogDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+// DiffCatalogs compares two versions of a catalog. A family counts as updated
+// when its version or lastModified changed, families cached before we tracked
+// those values are not reported as updated.
+func DiffCatalogs(previous, current []FontFamilyData) CatalogDiff {
+	diff := CatalogDiff{
+		RefreshedAt: time.Now().UTC(),
+		Added:       []string{},
+		Removed:     []string{},
+		Updated:     []CatalogFamilyChange{},
+	}
+
+	previousByName := make(map[string]FontFamilyData, len(previous))
+	for _, f := range previous {
+		previousByName[f.Name] = f
+	}
+
+	currentNames := make(map[string]bool, len(current))
+	for _, f := range current {
+		currentNames[f.Name] = true
+
+		old, found := previousByName[f.Name]
+		if !found {
+			diff.Added = append(diff.Added, f.Name)
+			continue
+		}
+
+		versionChanged := old.Version != "" && old.Version != f.Version
+		modifiedChanged := old.LastModified != "" && old.LastModified != f.LastModified
+		if versionChanged || modifiedChanged {
+			diff.Updated = append(diff.Updated, CatalogFamilyChange{
+				Name:                 f.Name,
+				PreviousVersion:      old.Version,
+				Version:              f.Version,
+				PreviousLastModified: old.LastModified,
+				LastModified:         f.LastModified,
+			})
+		}
+	}
+
+	for _, f := range previous {
+		if !currentNames[f.Name] {
+			diff.Removed = append(diff.Removed, f.Name)
+		}
+	}
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Updated, func(a, b CatalogFamilyChange) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return diff
+}
+
+// GetCatalogRefreshInterval reads CATALOG_REFRESH_INTERVAL (e.g. "6h"), a
+// value <= 0 disables the scheduled refresh.
+func GetCatalogRefreshInterval() time.Duration {
+	return utils.GetEnvDuration("CATALOG_REFRESH_INTERVAL", defaultCatalogRefreshInterval)
+}
+
+// RefreshCatalog re-fetches the provider's catalog, the provider swaps the new
+// catalog in once it has been fetched successfully. The diff against the
+// previous catalog is stored and returned. Refreshes of the same provider run
+// one at a time, so each change is recorded once.
+func (f *FontProvider) RefreshCatalog() (*CatalogDiff, error) {
+	f.refreshMu.Lock()
+	defer f.refreshMu.Unlock()
+
+	startedAt := time.Now()
+	defer func() { logger.Debug("[%s.RefreshCatalog]: %v", f.Id, time.Since(startedAt)) }()
+
+	previous := f.GetFontCache().All()
+
+	current, err := f.CacheFonts()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffCatalogs(previous, current)
+
+	// Drop parsed fonts of families whose files may have changed
+	for _, change := range diff.Updated {
+		if family, found := f.GetFontCache().Get(change.Name); found {
+			FontProviders.ForgetParsedFonts(f.Id, family)
+		}
+	}
+
+	f.diffMu.Lock()
+	f.lastDiff = &diff
+	f.diffMu.Unlock()
+
+	if err := saveCacheData(f.GetPath("diff.json"), diff); err != nil {
+		logger.Error("Failed to save catalog diff for provider %s: %v", f.Id, err)
+	}
+
+	if !diff.IsEmpty() {
+		if err := saveCatalogSnapshot(f.Id, NewCatalogSnapshot(diff.RefreshedAt, current)); err != nil {
+			logger.Error("Failed to save catalog snapshot for provider %s: %v", f.Id, err)
+		}
+
+		if err := pruneCatalogSnapshots(f.Id, GetCatalogHistoryMaxSnapshots()); err != nil {
+			logger.Error("Failed to prune catalog history for provider %s: %v", f.Id, err)
+		}
+	}
+
+	if err := saveProviderCacheToDisk(f); err != nil {
+		logger.Error("Failed to save cache for provider %s: %v", f.Id, err)
+	}
+
+	if err := loadMissingLicenses(f, current); err != nil {
+		logger.Error("Failed to load licenses for provider %s: %v", f.Id, err)
+	}
+
+	return &diff, nil
+}
+
+// GetLastCatalogDiff returns the diff of the most recent refresh, or nil when
+// the catalog hasn't been refreshed yet.
+func (f *FontProvider) GetLastCatalogDiff() *CatalogDiff {
+	f.diffMu.Lock()
+	defer f.diffMu.Unlock()
+
+	return f.lastDiff
+}
+
+func (f *FontProvider) loadLastCatalogDiff() error {
+	diff := new(CatalogDiff)
+
+	loaded, err := loadCacheData(f.GetPath("diff.json"), diff)
+	if err != nil || !loaded {
+		return err
+	}
+
+	f.diffMu.Lock()
+	f.lastDiff = diff
+	f.diffMu.Unlock()
+
+	return nil
+}
+
+// StartCatalogRefresh refreshes every provider's catalog on the given interval
+// until the process exits.
+func (s *FontProviderService) StartCatalogRefresh(interval time.Duration) {
+	if interval <= 0 {
+		logger.Info("Scheduled catalog refresh is disabled")
+		return
+	}
+
+	for _, provider := range s.Providers {
+		go func(provider *FontProvider) {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				diff, err := provider.RefreshCatalog()
+				if err != nil {
+					logger.Error("Failed to refresh catalog for provider %s: %v", provider.Id, err)
+					continue
+				}
+
+				logger.Info(
+					"Refreshed catalog for provider %s: %d added, %d removed, %d updated",
+					provider.Id, len(diff.Added), len(diff.Removed), len(diff.Updated),
+				)
+			}
+		}(provider)
+	}
+}
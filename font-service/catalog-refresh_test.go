@@ -0,0 +1,77 @@
+package font_service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffCatalogs(t *testing.T) {
+	family := func(name, version, lastModified string) FontFamilyData {
+		return FontFamilyData{Name: name, Version: version, LastModified: lastModified}
+	}
+
+	tests := []struct {
+		name     string
+		previous []FontFamilyData
+		current  []FontFamilyData
+		want     CatalogDiff
+	}{
+		{
+			name:     "unchanged",
+			previous: []FontFamilyData{family("Roboto", "v1", "2024-01-01")},
+			current:  []FontFamilyData{family("Roboto", "v1", "2024-01-01")},
+			want:     CatalogDiff{Added: []string{}, Removed: []string{}, Updated: []CatalogFamilyChange{}},
+		},
+		{
+			name:     "added and removed are sorted",
+			previous: []FontFamilyData{family("Roboto", "v1", ""), family("Lato", "v1", ""), family("Inter", "v1", "")},
+			current:  []FontFamilyData{family("Roboto", "v1", ""), family("Open Sans", "v1", ""), family("Abel", "v1", "")},
+			want: CatalogDiff{
+				Added:   []string{"Abel", "Open Sans"},
+				Removed: []string{"Inter", "Lato"},
+				Updated: []CatalogFamilyChange{},
+			},
+		},
+		{
+			name:     "version and last modified changes",
+			previous: []FontFamilyData{family("Roboto", "v1", "2024-01-01"), family("Lato", "v2", "2024-01-01")},
+			current:  []FontFamilyData{family("Roboto", "v2", "2024-01-01"), family("Lato", "v2", "2024-02-01")},
+			want: CatalogDiff{
+				Added:   []string{},
+				Removed: []string{},
+				Updated: []CatalogFamilyChange{
+					{Name: "Lato", PreviousVersion: "v2", Version: "v2", PreviousLastModified: "2024-01-01", LastModified: "2024-02-01"},
+					{Name: "Roboto", PreviousVersion: "v1", Version: "v2", PreviousLastModified: "2024-01-01", LastModified: "2024-01-01"},
+				},
+			},
+		},
+		{
+			name:     "families cached without a version aren't updated",
+			previous: []FontFamilyData{family("Roboto", "", "")},
+			current:  []FontFamilyData{family("Roboto", "v2", "2024-02-01")},
+			want:     CatalogDiff{Added: []string{}, Removed: []string{}, Updated: []CatalogFamilyChange{}},
+		},
+		{
+			name:    "empty previous catalog",
+			current: []FontFamilyData{family("Roboto", "v1", "")},
+			want:    CatalogDiff{Added: []string{"Roboto"}, Removed: []string{}, Updated: []CatalogFamilyChange{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffCatalogs(tt.previous, tt.current)
+			if got.RefreshedAt.IsZero() {
+				t.Error("RefreshedAt isn't set")
+			}
+
+			got.RefreshedAt = tt.want.RefreshedAt
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffCatalogs() = %+v, want %+v", got, tt.want)
+			}
+			if got.IsEmpty() != (len(tt.want.Added)+len(tt.want.Removed)+len(tt.want.Updated) == 0) {
+				t.Errorf("IsEmpty() = %v for %+v", got.IsEmpty(), got)
+			}
+		})
+	}
+}
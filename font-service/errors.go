@@ -0,0 +1,48 @@
+package font_service
+
+import (
+	"fmt"
+)
+
+// FontError is returned when a font can't be loaded, Code is a stable machine
+// readable identifier of the kind of error. Use errors.Is with one of the Err*
+// values below to check for a kind of error, and errors.As to read the code.
+type FontError struct {
+	Code    string
+	Message string
+	// The underlying error, if any
+	Err error
+}
+
+func (e *FontError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *FontError) Unwrap() error { return e.Err }
+
+// Is matches errors of the same kind, regardless of their message
+func (e *FontError) Is(target error) bool {
+	t, ok := target.(*FontError)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrUpstreamUnavailable = &FontError{Code: "upstream_unavailable", Message: "font provider is unavailable"}
+	ErrFontNotFound        = &FontError{Code: "font_not_found", Message: "font not found"}
+	ErrInvalidFontData     = &FontError{Code: "invalid_font_data", Message: "invalid font data"}
+	ErrVariantNotFound     = &FontError{Code: "variant_not_found", Message: "variant not found"}
+	// The options given by the client are invalid
+	ErrInvalidOptions = &FontError{Code: "invalid_options", Message: "invalid options"}
+)
+
+// newFontError creates an error of the same kind as base, with a more specific message
+func newFontError(base *FontError, err error, format string, args ...any) *FontError {
+	return &FontError{
+		Code:    base.Code,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/goccy/go-json"
 
+	"GoogleFontsPluginApi/logger"
 	"GoogleFontsPluginApi/utils"
 )
 
@@ -41,6 +42,16 @@ func loadProviderCacheFromDisk(provider IFontProvider) error {
 		}
 
 		cachedData = d
+	} else if isLegacyCacheData(cachedData) {
+		// Older caches are missing subsets/version/lastModified, try to re-fetch them
+		// but keep serving the old cache if the provider can't be reached
+		d, err := provider.CacheFonts()
+		if err != nil {
+			logger.Warning("Failed to re-fetch legacy cache for provider %s, using it as is: %v", provider.GetId(), err)
+			provider.InitializeFromCache(cachedData)
+		} else {
+			cachedData = d
+		}
 	} else {
 		provider.InitializeFromCache(cachedData)
 	}
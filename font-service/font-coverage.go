@@ -0,0 +1,114 @@
+package font_service
+
+import (
+	"math"
+	"unicode"
+
+	gotext "github.com/go-text/typesetting/font"
+)
+
+const fontCoverageMaxFamilies = 100
+
+// FontCoverage is how much of a text a font has glyphs for. Every rune is
+// counted once however often it appears, whitespace and invisible formatting
+// characters like zero width joiners aren't counted since fonts don't need
+// glyphs for them.
+type FontCoverage struct {
+	// The text that was checked, the family's sample text when none was given
+	Text string `json:"text"`
+	// Runes the font has no glyph for, in the order they first appear
+	Missing    []string `json:"missing"`
+	Covered    int      `json:"covered"`
+	Total      int      `json:"total"`
+	Percentage float64  `json:"percentage"`
+}
+
+// AnalyzeFontCoverage checks which runes of the text the font has a glyph
+// for, text without any runes to check is fully covered
+func AnalyzeFontCoverage(ft *gotext.Font, text string) FontCoverage {
+	coverage := FontCoverage{Text: text, Missing: []string{}, Percentage: 100}
+
+	seen := map[rune]bool{}
+	for _, r := range text {
+		if seen[r] || unicode.IsSpace(r) || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		seen[r] = true
+
+		coverage.Total++
+		if _, found := ft.NominalGlyph(r); found {
+			coverage.Covered++
+		} else {
+			coverage.Missing = append(coverage.Missing, string(r))
+		}
+	}
+
+	if coverage.Total > 0 {
+		coverage.Percentage = math.Round(float64(coverage.Covered)/float64(coverage.Total)*10000) / 100
+	}
+
+	return coverage
+}
+
+type FontCoverageOptions struct {
+	// Families to check, variants default to regular
+	Families []string `query:"families"`
+	// Text to check, the text the family's previews default to when empty
+	Text string `query:"text"`
+}
+
+// familyAndVariants returns the families of the options without duplicates
+func (o *FontCoverageOptions) familyAndVariants() []FontAndVariant {
+	return uniqueFamilyAndVariants(ExtractFamilyAndVariants(o.Families))
+}
+
+func (o *FontCoverageOptions) Validate() error {
+	families := o.familyAndVariants()
+	if len(families) == 0 {
+		return newFontError(ErrInvalidOptions, nil, "families is required")
+	}
+	if len(families) > fontCoverageMaxFamilies {
+		return newFontError(ErrInvalidOptions, nil, "at most %d families can be checked at once", fontCoverageMaxFamilies)
+	}
+	return nil
+}
+
+// FontCoverageResult is the coverage of one family, Err is set instead when
+// the family couldn't be checked
+type FontCoverageResult struct {
+	Family   FontAndVariant
+	Coverage FontCoverage
+	Err      error
+}
+
+// GetFontsCoverage checks the coverage of every family in the options once, a
+// few at a time since fonts that aren't loaded yet are downloaded. A family
+// that fails doesn't fail the others, its error is returned in its result.
+func GetFontsCoverage(provider IFontProvider, o *FontCoverageOptions) []FontCoverageResult {
+	return mapFamilies(o.familyAndVariants(), func(familyData FontAndVariant) FontCoverageResult {
+		coverage, err := getFontCoverage(provider, familyData, o.Text)
+		return FontCoverageResult{
+			Family:   familyData,
+			Coverage: coverage,
+			Err:      err,
+		}
+	})
+}
+
+func getFontCoverage(provider IFontProvider, familyData FontAndVariant, text string) (FontCoverage, error) {
+	data, err := provider.GetFontAndVariant(familyData.Family, familyData.Variant)
+	if err != nil {
+		return FontCoverage{}, err
+	}
+
+	ft, err := GetOrCacheFont(provider, data)
+	if err != nil {
+		return FontCoverage{}, err
+	}
+
+	if text == "" {
+		text = defaultPreviewText(data.Family, ft).text
+	}
+
+	return AnalyzeFontCoverage(ft, text), nil
+}
@@ -0,0 +1,53 @@
+package font_service
+
+import (
+	"slices"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestAnalyzeFontCoverage(t *testing.T) {
+	// Go Regular covers latin, greek and cyrillic but no CJK
+	ft, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		wantMissing []string
+		wantCovered int
+		wantTotal   int
+		wantPercent float64
+	}{
+		{name: "empty text", text: "", wantMissing: []string{}, wantPercent: 100},
+		{name: "only whitespace", text: " \t\n", wantMissing: []string{}, wantPercent: 100},
+		{name: "fully covered", text: "Roboto", wantMissing: []string{}, wantCovered: 4, wantTotal: 4, wantPercent: 100},
+		{name: "runes are counted once", text: "aab b", wantMissing: []string{}, wantCovered: 2, wantTotal: 2, wantPercent: 100},
+		{name: "invisible formatting is skipped", text: "a\u200db\u200e", wantMissing: []string{}, wantCovered: 2, wantTotal: 2, wantPercent: 100},
+		{name: "partly covered", text: "ab中", wantMissing: []string{"中"}, wantCovered: 2, wantTotal: 3, wantPercent: 66.67},
+		{name: "missing in order of appearance", text: "文a中文", wantMissing: []string{"文", "中"}, wantCovered: 1, wantTotal: 3, wantPercent: 33.33},
+		{name: "nothing covered", text: "中文", wantMissing: []string{"中", "文"}, wantCovered: 0, wantTotal: 2, wantPercent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnalyzeFontCoverage(ft, tt.text)
+
+			if got.Text != tt.text {
+				t.Errorf("text = %q, want %q", got.Text, tt.text)
+			}
+			if !slices.Equal(got.Missing, tt.wantMissing) || got.Missing == nil {
+				t.Errorf("missing = %#v, want %#v", got.Missing, tt.wantMissing)
+			}
+			if got.Covered != tt.wantCovered || got.Total != tt.wantTotal {
+				t.Errorf("covered %d of %d, want %d of %d", got.Covered, got.Total, tt.wantCovered, tt.wantTotal)
+			}
+			if got.Percentage != tt.wantPercent {
+				t.Errorf("percentage = %v, want %v", got.Percentage, tt.wantPercent)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package font_service
+
+// FontFacets holds the number of fonts available for each filter option
+type FontFacets struct {
+	Categories map[string]int `json:"categories"`
+	Subsets    map[string]int `json:"subsets"`
+}
+
+// GetFontFacets counts the fonts per category and subset for the given filters.
+//
+// Categories are OR'd together, so category counts ignore the category filter,
+// selecting another category adds its fonts to the results. Subsets are AND'd,
+// so subset counts are taken from the current results, they're the number of
+// fonts left when that subset is also selected.
+func GetFontFacets(provider IFontProvider, opts *GetFontsFilters) FontFacets {
+	facets := FontFacets{
+		Categories: map[string]int{},
+		Subsets:    map[string]int{},
+	}
+
+	if opts == nil {
+		opts = &GetFontsFilters{}
+	}
+
+	withoutCategories := *opts
+	withoutCategories.Categories = nil
+
+	for _, category := range provider.GetCategories() {
+		facets.Categories[category] = 0
+	}
+
+	for font := range provider.GetFontCache().Iterator() {
+		if !withoutCategories.CanAddToResults(&font) {
+			continue
+		}
+
+		facets.Categories[font.Category]++
+
+		if opts.HasCategory() && !opts.CanAddToResults(&font) {
+			continue
+		}
+
+		for _, subset := range font.Subsets {
+			facets.Subsets[subset]++
+		}
+	}
+
+	return facets
+}
@@ -0,0 +1,157 @@
+package font_service
+
+import (
+	"bytes"
+	"sync"
+
+	gotext "github.com/go-text/typesetting/font"
+	"golang.org/x/sync/singleflight"
+
+	"GoogleFontsPluginApi/cache"
+	"GoogleFontsPluginApi/logger"
+	"GoogleFontsPluginApi/utils"
+)
+
+const defaultFontDownloadConcurrency = 8
+
+var (
+	// Concurrent requests for the same variant share a single download
+	fontLoadGroup singleflight.Group
+	// Limits how many fonts are downloaded at the same time, FONT_DOWNLOAD_CONCURRENCY
+	fontDownloadSlots = sync.OnceValue(func() chan struct{} {
+		return make(chan struct{}, max(1, utils.GetEnvInt("FONT_DOWNLOAD_CONCURRENCY", defaultFontDownloadConcurrency)))
+	})
+)
+
+// parsedFontCacheKey is the key of a parsed font in FontProviders.FontCache,
+// it includes the provider since two providers can have a family of the same name
+func parsedFontCacheKey(providerId string, data *FontFamilyAndVariantData) string {
+	return providerId + "/" + data.FontCacheKey()
+}
+
+// ForgetParsedFonts drops the parsed fonts of every variant of the family, so
+// they're loaded again the next time they're used
+func (s *FontProviderService) ForgetParsedFonts(providerId string, family FontFamilyData) {
+	for _, variant := range family.Variants {
+		data := &FontFamilyAndVariantData{Family: family, Variant: variant}
+		s.FontCache.Remove(parsedFontCacheKey(providerId, data))
+
+		if variable, found := data.VariableFont(); found {
+			s.FontCache.Remove(parsedFontCacheKey(providerId, variable))
+		}
+	}
+}
+
+func GetOrCacheFont(provider IFontProvider, data *FontFamilyAndVariantData) (*gotext.Font, error) {
+	return getOrCacheParsedFont(FontProviders.FontCache, provider, data)
+}
+
+// GetOrCacheVariableFont returns the variable font the variant is an instance
+// of, its variations are applied when a face of it is created
+func GetOrCacheVariableFont(provider IFontProvider, data *FontFamilyAndVariantData) (*gotext.Font, error) {
+	variable, found := data.VariableFont()
+	if !found {
+		return nil, newFontError(ErrInvalidOptions, nil, "%s is not a variable font", data.Family.Name)
+	}
+
+	return getOrCacheParsedFont(FontProviders.FontCache, provider, variable)
+}
+
+// GetFontFile returns the font file of the variant, or of the variable font
+// it's an instance of
+func GetFontFile(provider IFontProvider, data *FontFamilyAndVariantData, variable bool) ([]byte, error) {
+	if variable {
+		variableData, found := data.VariableFont()
+		if !found {
+			return nil, newFontError(ErrVariantNotFound, nil, "%s has no variable font", data.Family.Name)
+		}
+		data = variableData
+	}
+
+	fontData, _, err := readFontFile(provider, data)
+	return fontData, err
+}
+
+func parseFont(fontData []byte) (*gotext.Font, error) {
+	face, err := gotext.ParseTTF(bytes.NewReader(fontData))
+	if err != nil {
+		return nil, err
+	}
+	return face.Font, nil
+}
+
+func getOrCacheParsedFont(
+	fontCache *cache.TTLCache[string, *gotext.Font],
+	provider IFontProvider,
+	data *FontFamilyAndVariantData,
+) (*gotext.Font, error) {
+	key := parsedFontCacheKey(provider.GetId(), data)
+
+	if font, found := fontCache.Get(key); found {
+		return font, nil
+	}
+
+	ft, err, _ := fontLoadGroup.Do(key, func() (any, error) {
+		// Another request may have finished loading it while we were waiting to get here
+		if font, found := fontCache.Get(key); found {
+			return font, nil
+		}
+
+		ft, err := loadFont(provider, data)
+		if err != nil {
+			return nil, err
+		}
+
+		fontCache.Set(key, ft)
+
+		return ft, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ft.(*gotext.Font), nil
+}
+
+func loadFont(provider IFontProvider, data *FontFamilyAndVariantData) (*gotext.Font, error) {
+	fontData, stored, err := readFontFile(provider, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the font and create a font face
+	ft, err := parseFont(fontData)
+	if err != nil {
+		return nil, newFontError(ErrInvalidFontData, err, "failed to parse %s", data.Variant.FullName)
+	}
+
+	// Local files are already on disk, there's no point in keeping another copy
+	if !stored && data.Variant.FilePath == "" {
+		if err := FontProviders.GetFontFileStore(provider.GetId()).Put(data, fontData); err != nil {
+			logger.Warning("Failed to store font %s: %v", data.FontCacheKey(), err)
+		}
+	}
+
+	return ft, nil
+}
+
+// readFontFile returns the stored copy of the variant's file, or downloads it.
+// stored reports whether it came from the font file store.
+func readFontFile(provider IFontProvider, data *FontFamilyAndVariantData) (fontData []byte, stored bool, err error) {
+	if data.Variant.FilePath == "" {
+		if fontData, found := FontProviders.GetFontFileStore(provider.GetId()).Get(data); found {
+			return fontData, true, nil
+		}
+	}
+
+	fontData, err = downloadFont(provider, data)
+	return fontData, false, err
+}
+
+func downloadFont(provider IFontProvider, data *FontFamilyAndVariantData) ([]byte, error) {
+	slots := fontDownloadSlots()
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	return provider.FetchFontData(data.Variant)
+}
@@ -1,29 +1,68 @@
 package font_service
 
 import (
-	"fmt"
-	"image/color"
+	"bytes"
+	"cmp"
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/fogleman/gg"
-	"github.com/golang/freetype/truetype"
-	"golang.org/x/image/font"
+	gotext "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"golang.org/x/image/vector"
 
 	"GoogleFontsPluginApi/logger"
+	"GoogleFontsPluginApi/utils"
 )
 
 type FontPreviewResultType string
 
 const (
+	// The encoded image as a base64 string
 	FontPreviewResultTypeBase64 FontPreviewResultType = "base64"
-	FontPreviewResultTypePng    FontPreviewResultType = "png"
+	// The encoded image itself
+	FontPreviewResultTypeRaw FontPreviewResultType = "raw"
+	// Older name of raw, from when png was the only format
+	FontPreviewResultTypePng FontPreviewResultType = "png"
+	// Glyph outlines as svg paths, stays crisp at any zoom level
+	FontPreviewResultTypeSvg FontPreviewResultType = "svg"
+	// Multi preview only, every family in a single image with a manifest of
+	// where each family is
+	FontPreviewResultTypeSprite FontPreviewResultType = "sprite"
 )
 
 type CreateFontPreviewOptions struct {
 	Families   []string              `query:"families"`
 	Text       string                `query:"text"`
 	ResultType FontPreviewResultType `query:"resultType,default:png"`
-	Small      bool                  `query:"small,default:false"`
+	// Whether to use the small preset, "true" or "false". A string so an
+	// unfilled template placeholder falls back to the large preset.
+	Small string `query:"small"`
+	// Image format of raster previews and sprites, and the quality of jpeg
+	// images between 1 and 100, the other formats are lossless
+	Format  PreviewFormat `query:"format"`
+	Quality int           `query:"quality"`
+	// Text and background colors, hex or rgb(a), see parsePreviewColor
+	Color      string `query:"color"`
+	Background string `query:"background"`
+	// Space kept free on every side of the text in pixels, empty for the
+	// default. A string so an empty value falls back to the default too.
+	Padding string       `query:"padding"`
+	Align   PreviewAlign `query:"align"`
+	Fit     PreviewFit   `query:"fit"`
+	// Custom size in pixels and font size in points, each replaces the value
+	// of the small or large preset when given
+	Width    float64 `query:"width"`
+	Height   float64 `query:"height"`
+	FontSize float64 `query:"fontSize"`
+	// Pixel density, 2 renders a retina image with twice the width and height
+	// at twice the DPI. Defaults to 1
+	Scale float64 `query:"scale"`
+	// Axis values of variable fonts as tag:value pairs, e.g. wght:650,opsz:24
+	Axes []string `query:"axes"`
 }
 type FontAndVariant struct {
 	Family  string
@@ -32,12 +71,14 @@ type FontAndVariant struct {
 
 func (f FontAndVariant) FullName() string { return f.Family + ":" + f.Variant }
 
+// ExtractFamilyAndVariant splits "family:variant", the variant defaults to
+// regular when it's left out
 func ExtractFamilyAndVariant(familyStr string) FontAndVariant {
-	data := FontAndVariant{}
+	data := FontAndVariant{Variant: defaultPreviewVariant}
 
 	parts := strings.Split(familyStr, ":")
 	data.Family = parts[0]
-	if len(parts) > 1 {
+	if len(parts) > 1 && parts[1] != "" {
 		data.Variant = parts[1]
 	}
 
@@ -55,11 +96,54 @@ func ExtractFamilyAndVariants(familyStrs []string) []FontAndVariant {
 
 func (o *CreateFontPreviewOptions) FirstFamilyAndVariant() (FontAndVariant, error) {
 	if len(o.Families) == 0 {
-		return FontAndVariant{}, fmt.Errorf("no families specified")
+		return FontAndVariant{}, newFontError(ErrInvalidOptions, nil, "families is required")
 	}
 
 	return ExtractFamilyAndVariant(o.Families[0]), nil
 }
+
+// Validate checks the options and fills in the defaults for the ones that
+// weren't given, the query binder doesn't apply the default tags
+func (o *CreateFontPreviewOptions) Validate() error {
+	if len(o.Families) == 0 {
+		return newFontError(ErrInvalidOptions, nil, "families is required")
+	}
+
+	if o.ResultType == "" || o.ResultType == FontPreviewResultTypePng {
+		o.ResultType = FontPreviewResultTypeRaw
+	}
+	o.Color = cmp.Or(o.Color, defaultPreviewColor)
+	o.Background = cmp.Or(o.Background, defaultPreviewBackground)
+	o.Align = cmp.Or(o.Align, PreviewAlignCenter)
+	o.Fit = cmp.Or(o.Fit, PreviewFitWrap)
+	o.Format = cmp.Or(o.Format, PreviewFormatPng)
+
+	if err := o.Format.validate(); err != nil {
+		return err
+	}
+	if o.Quality != 0 && o.Format != PreviewFormatJpeg {
+		return newFontError(ErrInvalidOptions, nil, "quality is only supported for jpeg, %s is lossless", o.Format)
+	}
+	if o.Quality != 0 && (o.Quality < 1 || o.Quality > 100) {
+		return newFontError(ErrInvalidOptions, nil, "quality must be between 1 and 100")
+	}
+
+	switch o.ResultType {
+	case FontPreviewResultTypeBase64, FontPreviewResultTypeRaw, FontPreviewResultTypeSvg, FontPreviewResultTypeSprite:
+	default:
+		return newFontError(ErrInvalidOptions, nil, "unknown result type %q", o.ResultType)
+	}
+
+	if _, err := o.size(); err != nil {
+		return err
+	}
+	if _, err := o.axisValues(); err != nil {
+		return err
+	}
+
+	_, err := o.style()
+	return err
+}
 func (o *CreateFontPreviewOptions) FamilyAndVariants() []FontAndVariant {
 	return ExtractFamilyAndVariants(o.Families)
 }
@@ -68,47 +152,397 @@ type previewSize struct {
 	width    float64
 	height   float64
 	fontSize float64
+	scale    float64
 }
 
 var previewSizes = map[bool]previewSize{
 	// true = small - small is more like a banner
-	true: {800, 100, 30},
+	true: {800, 100, 30, 1},
 	// false = large - large is more like a cover image
-	false: {400, 200, 40},
+	false: {400, 200, 40, 1},
 }
 
-func CreateFontPreview(
+const (
+	previewDPI         = 96
+	previewLineSpacing = 1.5
+
+	// The variant of families given without one
+	defaultPreviewVariant = "regular"
+
+	defaultPreviewMaxDimension = 2048
+	previewMaxScale            = 4
+	previewMaxFontSize         = 500
+
+	// Shrinking stops once the font size is known to within this many points
+	previewShrinkPrecision = 0.25
+	previewMinFontSize     = 1
+)
+
+// GetPreviewMaxDimension reads PREVIEW_MAX_DIMENSION, the largest width or
+// height in pixels of a rendered preview, scale included
+func GetPreviewMaxDimension() float64 {
+	return float64(utils.GetEnvInt("PREVIEW_MAX_DIMENSION", defaultPreviewMaxDimension))
+}
+
+// text is the text the client asked for, empty when it left it out
+func (o *CreateFontPreviewOptions) text() string {
+	return templateValue(o.Text)
+}
+
+func (o *CreateFontPreviewOptions) small() (bool, error) {
+	small := templateValue(o.Small)
+	if small == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(small)
+	if err != nil {
+		return false, newFontError(ErrInvalidOptions, err, "invalid small %q, expected true or false", o.Small)
+	}
+	return value, nil
+}
+
+// size returns the preset picked by Small with the custom size applied
+func (o *CreateFontPreviewOptions) size() (previewSize, error) {
+	small, err := o.small()
+	if err != nil {
+		return previewSize{}, err
+	}
+
+	size := previewSizes[small]
+	size.width = cmp.Or(o.Width, size.width)
+	size.height = cmp.Or(o.Height, size.height)
+	size.fontSize = cmp.Or(o.FontSize, size.fontSize)
+	size.scale = cmp.Or(o.Scale, size.scale)
+
+	// The checks are written so NaN fails them too
+	if !(size.scale > 0 && size.scale <= previewMaxScale) {
+		return size, newFontError(ErrInvalidOptions, nil, "scale must be above 0 and at most %d", previewMaxScale)
+	}
+
+	maxDimension := GetPreviewMaxDimension()
+	if !(size.width >= 1 && size.width*size.scale <= maxDimension) {
+		return size, newFontError(ErrInvalidOptions, nil, "width must be between 1 and %v pixels, scale included", maxDimension)
+	}
+	if !(size.height >= 1 && size.height*size.scale <= maxDimension) {
+		return size, newFontError(ErrInvalidOptions, nil, "height must be between 1 and %v pixels, scale included", maxDimension)
+	}
+	if !(size.fontSize > 0 && size.fontSize <= previewMaxFontSize) {
+		return size, newFontError(ErrInvalidOptions, nil, "fontSize must be above 0 and at most %d", previewMaxFontSize)
+	}
+
+	return size, nil
+}
+
+// pixels returns the size of the rendered image, rendering the font at scale
+// times the size is the same as rendering it at scale times the DPI
+func (s previewSize) pixels() previewSize {
+	return previewSize{
+		width:    max(1, math.Round(s.width*s.scale)),
+		height:   max(1, math.Round(s.height*s.scale)),
+		fontSize: s.fontSize * s.scale,
+		scale:    1,
+	}
+}
+
+type previewLine struct {
+	shapedLine
+	// x is the left edge of the line and y its baseline
+	x, y float64
+}
+
+// previewLayout is the shaped, wrapped and centered text of a preview, shared
+// by the raster and svg output so both place every glyph in the same spot
+type previewLayout struct {
+	font  *previewFont
+	size  previewSize
+	style previewStyle
+	// Pixels per font unit at the font size
+	scale float64
+	lines []previewLine
+}
+
+// textFor returns the text of the preview of the family rendered with the
+// font, see defaultPreviewText for when none was given
+func (o *CreateFontPreviewOptions) textFor(data *FontFamilyAndVariantData, ft *gotext.Font) previewText {
+	if text := o.text(); text != "" {
+		return previewText{text: text}
+	}
+	return defaultPreviewText(data.Family, ft)
+}
+
+func newPreviewLayout(ft *previewFont, r *CreateFontPreviewOptions, text previewText) (*previewLayout, error) {
+	logicalSize, err := r.size()
+	if err != nil {
+		return nil, err
+	}
+
+	style, err := r.style()
+	if err != nil {
+		return nil, err
+	}
+
+	size := logicalSize.pixels()
+	style.padding *= logicalSize.scale
+
+	contentWidth := size.width - style.padding*2
+	contentHeight := size.height - style.padding*2
+
+	if style.fit == PreviewFitShrink {
+		size.fontSize = shrinkFontSize(ft, text, size.fontSize, contentWidth, contentHeight)
+	}
+
+	scale := ft.unitScale(size.fontSize)
+
+	// Only wrap mode wraps long lines
+	maxWidth := 0
+	if style.fit == PreviewFitWrap {
+		maxWidth = max(1, int(contentWidth/scale))
+	}
+
+	lines := ft.shapeLines(text, maxWidth)
+	lineHeight := previewLineHeight(size.fontSize)
+	textHeight := previewTextHeight(len(lines), lineHeight)
+
+	layout := &previewLayout{
+		font:  ft,
+		size:  size,
+		style: style,
+		scale: scale,
+		lines: make([]previewLine, len(lines)),
+	}
+
+	y := size.height/2 - textHeight/2
+	for i, line := range lines {
+		lineWidth := line.width * scale
+
+		x := size.width/2 - lineWidth/2
+		switch style.align {
+		case PreviewAlignLeft:
+			x = style.padding
+		case PreviewAlignRight:
+			x = size.width - style.padding - lineWidth
+		}
+
+		layout.lines[i] = previewLine{
+			shapedLine: line,
+			x:          x,
+			y:          y + lineHeight,
+		}
+		y += lineHeight * previewLineSpacing
+	}
+
+	return layout, nil
+}
+
+// previewLineHeight is one em at the font size in points, lines are this
+// times previewLineSpacing apart
+func previewLineHeight(fontSize float64) float64 {
+	return fontSize * previewDPI / 72
+}
+
+// previewTextHeight is the height of the lines, from the top of the first to
+// the bottom of the last
+func previewTextHeight(lineCount int, lineHeight float64) float64 {
+	return float64(lineCount)*lineHeight*previewLineSpacing - (previewLineSpacing-1)*lineHeight
+}
+
+// shrinkFontSize binary searches the largest font size, up to maxSize, at
+// which every line of the text fits in the content box. Text that doesn't fit
+// even at the smallest size is rendered at the smallest size.
+func shrinkFontSize(ft *previewFont, text previewText, maxSize, width, height float64) float64 {
+	// The lines aren't wrapped, so they're shaped once and scaled to every size
+	lines := ft.shapeLines(text, 0)
+
+	fits := func(fontSize float64) bool {
+		if previewTextHeight(len(lines), previewLineHeight(fontSize)) > height {
+			return false
+		}
+
+		scale := ft.unitScale(fontSize)
+		for _, line := range lines {
+			if line.width*scale > width {
+				return false
+			}
+		}
+		return true
+	}
+
+	if fits(maxSize) {
+		return maxSize
+	}
+
+	low, high := float64(previewMinFontSize), maxSize
+	for high-low > previewShrinkPrecision {
+		mid := (low + high) / 2
+		if fits(mid) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return low
+}
+
+// loadPreviewFont returns the font the preview is rendered with and the axis
+// values it's rendered at, the variable font when the preview has axis values
+func loadPreviewFont(
+	provider IFontProvider,
+	r *CreateFontPreviewOptions,
+	data *FontFamilyAndVariantData,
+) (*gotext.Font, []previewAxisValue, error) {
+	variations, err := r.variations(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ft *gotext.Font
+	if len(variations) > 0 {
+		ft, err = GetOrCacheVariableFont(provider, data)
+	} else {
+		ft, err = GetOrCacheFont(provider, data)
+	}
+	if err != nil {
+		logger.Error("Failed to get font: %v", err)
+		return nil, nil, err
+	}
+
+	return ft, variations, nil
+}
+
+// previewPath receives glyph outlines, vector.Rasterizer for raster previews
+// and svgPath for svg
+type previewPath interface {
+	MoveTo(x, y float32)
+	LineTo(x, y float32)
+	QuadTo(bx, by, x, y float32)
+	CubeTo(bx, by, cx, cy, x, y float32)
+	ClosePath()
+}
+
+// addLineOutlines adds the unhinted outlines of the line's glyphs to the path,
+// in pixels of the preview moved by the offset
+func (l *previewLayout) addLineOutlines(path previewPath, line previewLine, offsetX, offsetY float64) {
+	for _, g := range line.glyphs {
+		originX := line.x + g.x*l.scale + offsetX
+		originY := line.y - g.y*l.scale + offsetY
+
+		// Font units point up, so y is flipped around the baseline
+		point := func(p gotext.SegmentPoint) (float32, float32) {
+			return float32(originX + float64(p.X)*l.scale), float32(originY - float64(p.Y)*l.scale)
+		}
+
+		segments := l.font.outline(g.id)
+		for i, s := range segments {
+			switch s.Op {
+			case ot.SegmentOpMoveTo:
+				if i > 0 {
+					path.ClosePath()
+				}
+				path.MoveTo(point(s.Args[0]))
+			case ot.SegmentOpLineTo:
+				path.LineTo(point(s.Args[0]))
+			case ot.SegmentOpQuadTo:
+				bx, by := point(s.Args[0])
+				x, y := point(s.Args[1])
+				path.QuadTo(bx, by, x, y)
+			case ot.SegmentOpCubeTo:
+				bx, by := point(s.Args[0])
+				cx, cy := point(s.Args[1])
+				x, y := point(s.Args[2])
+				path.CubeTo(bx, by, cx, cy, x, y)
+			}
+		}
+		if len(segments) > 0 {
+			path.ClosePath()
+		}
+	}
+}
+
+func (l *previewLayout) draw() *gg.Context {
+	dc := gg.NewContext(int(l.size.width), int(l.size.height))
+	dc.SetColor(l.style.background)
+	dc.Clear()
+
+	// Clip mode cuts the text off at the padding, by only drawing inside it
+	area := dc.Image().Bounds()
+	if l.style.fit == PreviewFitClip {
+		x, y, w, h := l.contentBox()
+		area = area.Intersect(image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h))))
+	}
+	if area.Empty() {
+		return dc
+	}
+
+	// The rasterizer covers the area, so glyphs are moved by its top left corner
+	var rasterizer vector.Rasterizer
+	rasterizer.Reset(area.Dx(), area.Dy())
+	for _, line := range l.lines {
+		l.addLineOutlines(&rasterizer, line, -float64(area.Min.X), -float64(area.Min.Y))
+	}
+	rasterizer.Draw(dc.Image().(draw.Image), area, image.NewUniform(l.style.color), image.Point{})
+
+	return dc
+}
+
+// contentBox is the area inside the padding
+func (l *previewLayout) contentBox() (x, y, width, height float64) {
+	p := l.style.padding
+	return p, p, l.size.width - p*2, l.size.height - p*2
+}
+
+// RenderFontPreview renders the preview in the requested result type, svg
+// markup for svg and an image in the requested format for raw and base64. Previews are served
+// from the preview cache when possible.
+func RenderFontPreview(
 	provider IFontProvider,
 	r *CreateFontPreviewOptions,
 	familyData FontAndVariant,
-) (*gg.Context, error) {
-	data, err := provider.GetFontAndVariant(familyData.Family, familyData.Variant)
+) ([]byte, error) {
+	preview, err := PrepareFontPreview(provider, r, familyData)
 	if err != nil {
 		return nil, err
 	}
 
-	ft, err := GetOrCacheFont(data)
+	rendered, err := preview.Render()
 	if err != nil {
-		logger.Error("Failed to get font: %v", err)
 		return nil, err
 	}
 
-	size := previewSizes[r.Small]
+	return rendered.Data, nil
+}
 
-	fontFace := truetype.NewFace(ft, &truetype.Options{
-		Size:    size.fontSize,
-		DPI:     96,
-		Hinting: font.HintingFull,
-	})
+// renderFontPreview renders the preview without going through the cache, the
+// coverage of its text is checked with the font it's rendered with
+func renderFontPreview(
+	provider IFontProvider,
+	r *CreateFontPreviewOptions,
+	data *FontFamilyAndVariantData,
+) (*RenderedPreview, error) {
+	ft, variations, err := loadPreviewFont(provider, r, data)
+	if err != nil {
+		return nil, err
+	}
 
-	dc := gg.NewContext(int(size.width), int(size.height))
-	dc.SetColor(color.Transparent)
-	dc.Clear()
+	text := r.textFor(data, ft)
+	layout, err := newPreviewLayout(newPreviewFont(ft, variations), r, text)
+	if err != nil {
+		return nil, err
+	}
 
-	dc.SetFontFace(fontFace)
-	dc.SetColor(color.White)
-	// dc.DrawStringAnchored(r.Text, size.width/2, size.height/2, 0.5, 0.5)
-	dc.DrawStringWrapped(r.Text, size.width/2, size.height/2, 0.5, 0.5, size.width-20, 1.5, gg.AlignCenter)
+	var buf bytes.Buffer
+	if r.ResultType == FontPreviewResultTypeSvg {
+		err = layout.encodeSVG(&buf)
+	} else {
+		err = encodePreviewImage(&buf, layout.draw().Image(), cmp.Or(r.Format, PreviewFormatPng), r.quality(), layout.style.background)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return dc, nil
+	return &RenderedPreview{
+		Data:     buf.Bytes(),
+		Coverage: AnalyzeFontCoverage(ft, text.text),
+	}, nil
 }
@@ -0,0 +1,55 @@
+package font_service
+
+import "testing"
+
+func TestExtractFamilyAndVariant(t *testing.T) {
+	tests := []struct {
+		value string
+		want  FontAndVariant
+	}{
+		{value: "Inter", want: FontAndVariant{Family: "Inter", Variant: "regular"}},
+		{value: "Inter:", want: FontAndVariant{Family: "Inter", Variant: "regular"}},
+		{value: "Inter:700", want: FontAndVariant{Family: "Inter", Variant: "700"}},
+		{value: "Open Sans:italic", want: FontAndVariant{Family: "Open Sans", Variant: "italic"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ExtractFamilyAndVariant(tt.value); got != tt.want {
+				t.Errorf("ExtractFamilyAndVariant(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstFamilyAndVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		families []string
+		want     FontAndVariant
+		wantErr  bool
+	}{
+		{name: "family without a variant", families: []string{"Inter"}, want: FontAndVariant{Family: "Inter", Variant: "regular"}},
+		{name: "first of several", families: []string{"Inter:700", "Roboto"}, want: FontAndVariant{Family: "Inter", Variant: "700"}},
+		{name: "no families", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &CreateFontPreviewOptions{Families: tt.families}
+			got, err := o.FirstFamilyAndVariant()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("FirstFamilyAndVariant() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FirstFamilyAndVariant() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package font_service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores for each kind of match, a better kind of match always outranks a
+// worse one. Matches on the category or designer are weighted down so they
+// rank below matches on the family name.
+const (
+	searchScoreExact       = 1.0
+	searchScorePrefix      = 0.8
+	searchScoreTokenPrefix = 0.7
+	searchScoreContains    = 0.6
+	searchScoreFuzzy       = 0.5
+
+	searchSecondaryFieldWeight = 0.5
+)
+
+type searchQuery struct {
+	tokens  []string
+	compact string
+}
+
+// searchTokens lower cases the value and splits it on anything that isn't a
+// letter or digit, so "open-sans", "Open Sans" and "open_sans" all match.
+func searchTokens(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newSearchQuery(value string) searchQuery {
+	tokens := searchTokens(value)
+	return searchQuery{
+		tokens:  tokens,
+		compact: strings.Join(tokens, ""),
+	}
+}
+
+// SearchScore returns how well the font matches the search, between 0 (no
+// match) and 1 (exact match on the name)
+func (f *GetFontsFilters) SearchScore(font *FontFamilyData) float64 {
+	if !f.HasSearch() {
+		return 0
+	}
+
+	query := newSearchQuery(*f.Search)
+	if query.compact == "" {
+		return 0
+	}
+
+	score := query.score(font.Name)
+	score = max(score, query.score(font.Category)*searchSecondaryFieldWeight)
+	if font.Designer != "" {
+		score = max(score, query.score(font.Designer)*searchSecondaryFieldWeight)
+	}
+
+	return score
+}
+
+func (q searchQuery) score(value string) float64 {
+	tokens := searchTokens(value)
+	compact := strings.Join(tokens, "")
+	if compact == "" {
+		return 0
+	}
+
+	// How much of the value the query covers, so "Roboto" ranks above "Roboto Slab" for "robo"
+	coverage := float64(len(q.compact)) / float64(len(compact))
+
+	switch {
+	case compact == q.compact:
+		return searchScoreExact
+	case strings.HasPrefix(compact, q.compact):
+		return searchScorePrefix + 0.1*coverage
+	case q.allTokensMatch(tokens, strings.HasPrefix):
+		return searchScoreTokenPrefix + 0.1*coverage
+	case strings.Contains(compact, q.compact):
+		return searchScoreContains + 0.1*coverage
+	}
+
+	similarity := q.fuzzySimilarity(tokens, compact)
+	if similarity == 0 {
+		return 0
+	}
+
+	return searchScoreFuzzy * similarity
+}
+
+func (q searchQuery) allTokensMatch(tokens []string, match func(token, queryToken string) bool) bool {
+	for _, queryToken := range q.tokens {
+		found := false
+		for _, token := range tokens {
+			if match(token, queryToken) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzySimilarity allows a few typos per query token, each query token has
+// to be close to one of the value's tokens (or to the start of it, so partially
+// typed words still match). The query as a whole is also compared against the
+// value without spaces, for searches like "opensnas".
+func (q searchQuery) fuzzySimilarity(tokens []string, compact string) float64 {
+	total := 0.0
+	for _, queryToken := range q.tokens {
+		best := 0.0
+		for _, token := range tokens {
+			best = max(best, fuzzyTokenSimilarity(queryToken, token))
+		}
+		if best == 0 {
+			total = 0
+			break
+		}
+		total += best
+	}
+
+	tokensSimilarity := total / float64(len(q.tokens))
+
+	return max(tokensSimilarity, fuzzyTokenSimilarity(q.compact, compact))
+}
+
+func fuzzyTokenSimilarity(queryToken, token string) float64 {
+	allowed := allowedTypos(queryToken)
+	if allowed == 0 {
+		return 0
+	}
+
+	q := []rune(queryToken)
+	t := []rune(token)
+
+	distance := editDistance(q, t)
+	// Compare against the start of the token too, allowing for one more or less character
+	for n := len(q) - 1; n <= len(q)+1; n++ {
+		if n > 0 && n < len(t) {
+			distance = min(distance, editDistance(q, t[:n]))
+		}
+	}
+
+	if distance > allowed {
+		return 0
+	}
+
+	return 1 - float64(distance)/float64(len(q)+1)
+}
+
+func allowedTypos(token string) int {
+	switch n := len([]rune(token)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the optimal string alignment distance, a levenshtein
+// distance which also counts swapping two neighbouring characters as one edit
+func editDistance(a, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			rows[i][j] = min(
+				rows[i-1][j]+1,
+				rows[i][j-1]+1,
+				rows[i-1][j-1]+cost,
+			)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(a)][len(b)]
+}
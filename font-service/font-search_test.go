@@ -0,0 +1,110 @@
+package font_service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"roboto", "roboto", 0},
+		{"", "lato", 4},
+		{"lato", "", 4},
+		{"robto", "roboto", 1},
+		{"robotto", "roboto", 1},
+		{"rabota", "roboto", 2},
+		{"kitten", "sitting", 3},
+		// Swapping two neighbouring characters is a single edit
+		{"ab", "ba", 1},
+		{"opensnas", "opensans", 1},
+		{"ca", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchScore(t *testing.T) {
+	// Fuzzy and secondary field scores depend on the lengths involved, those
+	// cases only check that the font matches
+	const anyMatch = -1
+
+	tests := []struct {
+		search string
+		font   FontFamilyData
+		want   float64
+	}{
+		{search: "roboto", font: FontFamilyData{Name: "Roboto"}, want: searchScoreExact},
+		{search: "open-sans", font: FontFamilyData{Name: "Open Sans"}, want: searchScoreExact},
+		{search: "OPEN_SANS", font: FontFamilyData{Name: "Open Sans"}, want: searchScoreExact},
+		{search: "robto", font: FontFamilyData{Name: "Roboto"}, want: anyMatch},
+		{search: "opensnas", font: FontFamilyData{Name: "Open Sans"}, want: anyMatch},
+		{search: "sans", font: FontFamilyData{Name: "Lato", Category: "sans-serif"}, want: anyMatch},
+		{search: "khaled", font: FontFamilyData{Name: "Amiri", Designer: "Khaled Hosny"}, want: anyMatch},
+		// Short words don't allow any typos
+		{search: "rbt", font: FontFamilyData{Name: "Roboto"}, want: 0},
+		{search: "xyzzy", font: FontFamilyData{Name: "Roboto"}, want: 0},
+		{search: "---", font: FontFamilyData{Name: "Roboto"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.search+"/"+tt.font.Name, func(t *testing.T) {
+			filters := &GetFontsFilters{Search: &tt.search}
+			got := filters.SearchScore(&tt.font)
+
+			if tt.want == anyMatch {
+				if got <= 0 || got >= searchScoreExact {
+					t.Errorf("SearchScore(%q, %q) = %v, want a partial match", tt.search, tt.font.Name, got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("SearchScore(%q, %q) = %v, want %v", tt.search, tt.font.Name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	fonts := []FontFamilyData{
+		{Name: "Roboto", Category: "sans-serif", Order: FontFamilyOrderValues{Popularity: 1}},
+		{Name: "Open Sans", Category: "sans-serif", Order: FontFamilyOrderValues{Popularity: 2}},
+		{Name: "Roboto Slab", Category: "serif", Order: FontFamilyOrderValues{Popularity: 3}},
+		{Name: "Lato", Category: "sans-serif", Order: FontFamilyOrderValues{Popularity: 4}},
+		{Name: "Open Sans Condensed", Category: "sans-serif", Order: FontFamilyOrderValues{Popularity: 5}},
+		{Name: "Robot Crush", Category: "display", Order: FontFamilyOrderValues{Popularity: 6}},
+		{Name: "Source Sans 3", Category: "sans-serif", Order: FontFamilyOrderValues{Popularity: 7}},
+	}
+
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{search: "robto", want: []string{"Roboto", "Roboto Slab", "Robot Crush"}},
+		// Equally good matches are ordered by popularity
+		{search: "robo", want: []string{"Roboto", "Roboto Slab", "Robot Crush"}},
+		{search: "open-sans", want: []string{"Open Sans", "Open Sans Condensed"}},
+		// Names covering more of the search rank higher, categories rank last
+		{search: "sans", want: []string{"Open Sans", "Source Sans 3", "Open Sans Condensed", "Roboto", "Lato"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			filters := &GetFontsFilters{Search: &tt.search}
+			got := fontNames(filters.Apply(slices.Values(fonts)))
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("search %q = %v, want %v", tt.search, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package font_service
+
+import (
+	"cmp"
+	"strings"
+)
+
+type FontSort string
+
+const (
+	FontSortPopularity   FontSort = "popularity"
+	FontSortAlpha        FontSort = "alpha"
+	FontSortLastModified FontSort = "lastModified"
+	FontSortDateAdded    FontSort = "dateAdded"
+	FontSortVariantCount FontSort = "variantCount"
+	FontSortTrending     FontSort = "trending"
+	// Best search matches first, the default sort when searching
+	FontSortRelevance FontSort = "relevance"
+)
+
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "asc"
+	SortDesc SortDirection = "desc"
+)
+
+// fontSorters compare two fonts in ascending order of the sort's value, e.g.
+// the least popular or oldest font first. defaultDirection is what makes sense
+// when no order is given, most popular, newest, or A-Z.
+var fontSorters = map[FontSort]struct {
+	compare          func(a, b *FontFamilyData) int
+	defaultDirection SortDirection
+}{
+	FontSortPopularity: {
+		compare:          func(a, b *FontFamilyData) int { return cmp.Compare(b.Order.Popularity, a.Order.Popularity) },
+		defaultDirection: SortDesc,
+	},
+	FontSortTrending: {
+		compare:          func(a, b *FontFamilyData) int { return cmp.Compare(b.Order.Trending, a.Order.Trending) },
+		defaultDirection: SortDesc,
+	},
+	FontSortDateAdded: {
+		compare:          func(a, b *FontFamilyData) int { return cmp.Compare(b.Order.DateAdded, a.Order.DateAdded) },
+		defaultDirection: SortDesc,
+	},
+	FontSortLastModified: {
+		compare:          func(a, b *FontFamilyData) int { return strings.Compare(a.LastModified, b.LastModified) },
+		defaultDirection: SortDesc,
+	},
+	FontSortVariantCount: {
+		compare:          func(a, b *FontFamilyData) int { return cmp.Compare(len(a.Variants), len(b.Variants)) },
+		defaultDirection: SortDesc,
+	},
+	FontSortRelevance: {
+		compare: func(a, b *FontFamilyData) int {
+			// Equally relevant fonts are ordered by popularity
+			return cmp.Or(cmp.Compare(a.SearchScore, b.SearchScore), cmp.Compare(b.Order.Popularity, a.Order.Popularity))
+		},
+		defaultDirection: SortDesc,
+	},
+	FontSortAlpha: {
+		compare: func(a, b *FontFamilyData) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		},
+		defaultDirection: SortAsc,
+	},
+}
+
+func (f *GetFontsFilters) GetSort() FontSort {
+	if f == nil || f.Sort == "" {
+		if f.HasSearch() {
+			return FontSortRelevance
+		}
+		return FontSortPopularity
+	}
+	return f.Sort
+}
+
+func (f *GetFontsFilters) GetOrder() SortDirection {
+	if f == nil || f.Order == "" {
+		return fontSorters[f.GetSort()].defaultDirection
+	}
+	return f.Order
+}
+
+// SortKey identifies the ordering of the results, e.g. "popularity:desc"
+func (f *GetFontsFilters) SortKey() string {
+	return string(f.GetSort()) + ":" + string(f.GetOrder())
+}
+
+func (f *GetFontsFilters) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if _, found := fontSorters[f.GetSort()]; !found {
+		return newFontError(ErrInvalidOptions, nil, "unknown sort %q", f.Sort)
+	}
+	if order := f.GetOrder(); order != SortAsc && order != SortDesc {
+		return newFontError(ErrInvalidOptions, nil, "unknown order %q, expected asc or desc", f.Order)
+	}
+	return nil
+}
+
+// Compare orders fonts by the requested sort and direction. Ties are broken
+// on the name so the order, and therefore pagination, is always stable.
+func (f *GetFontsFilters) Compare(a, b FontFamilyData) int {
+	sorter, found := fontSorters[f.GetSort()]
+	if !found {
+		sorter = fontSorters[FontSortPopularity]
+	}
+
+	result := sorter.compare(&a, &b)
+	if f.GetOrder() == SortDesc {
+		result = -result
+	}
+
+	return cmp.Or(result, strings.Compare(a.Name, b.Name))
+}
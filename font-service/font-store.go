@@ -0,0 +1,241 @@
+package font_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"GoogleFontsPluginApi/cache"
+	"GoogleFontsPluginApi/logger"
+	"GoogleFontsPluginApi/utils"
+)
+
+const defaultFontStoreMaxMB = 1024
+
+type fontStoreEntry struct {
+	Path string `json:"path"`
+	// The url the file was downloaded from, when the catalog points the variant
+	// at a different url (e.g. a new version) the stored file is outdated
+	SourceURL  string    `json:"sourceUrl"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+// FontFileStore keeps downloaded font files on disk under
+// data/<provider>/fonts/<family>/<variant>.ttf so they survive restarts.
+// Files are verified against their sha256 when read, and the least recently
+// used files are evicted once the store grows past maxBytes. The index,
+// including when each file was last used, is saved to
+// data/<provider>/fonts/index.json so the eviction order survives restarts.
+type FontFileStore struct {
+	// Guards entries and writes of the index, files are read and written without it
+	mu sync.Mutex
+
+	providerId string
+	entries    map[string]*fontStoreEntry
+	// Key -> file size of the stored fonts, decides which files are evicted
+	lru *cache.LRUCache[string, int64]
+}
+
+// GetFontStoreMaxBytes reads FONT_STORE_MAX_MB, 0 means the store has no size limit
+func GetFontStoreMaxBytes() int64 {
+	return int64(utils.GetEnvInt("FONT_STORE_MAX_MB", defaultFontStoreMaxMB)) * 1024 * 1024
+}
+
+func NewFontFileStore(providerId string, maxBytes int64) *FontFileStore {
+	s := &FontFileStore{
+		providerId: providerId,
+		entries:    map[string]*fontStoreEntry{},
+		lru:        cache.NewLRU[string, int64](maxBytes, func(size int64) int64 { return size }),
+	}
+	s.lru.OnEvict(func(key string, _ int64) { s.remove(key) })
+
+	if _, err := loadCacheData(s.indexPath(), &s.entries); err != nil {
+		logger.Error("Failed to load font store index for provider %s: %v", providerId, err)
+		s.entries = map[string]*fontStoreEntry{}
+	}
+
+	for key, entry := range s.entries {
+		if !utils.FileExists(entry.Path) {
+			delete(s.entries, key)
+		}
+	}
+
+	// Oldest first, so the most recently used files end up at the front
+	keys := slices.SortedFunc(maps.Keys(s.entries), func(a, b string) int {
+		return s.entries[a].LastAccess.Compare(s.entries[b].LastAccess)
+	})
+	for _, key := range keys {
+		if !s.lru.Set(key, s.entries[key].Size) {
+			s.remove(key)
+		}
+	}
+
+	return s
+}
+
+func (s *FontFileStore) indexPath() string {
+	return GetProviderPath(s.providerId, "fonts", "index.json")
+}
+
+func (s *FontFileStore) filePath(data *FontFamilyAndVariantData) string {
+	ext := path.Ext(data.Variant.DownloadURL)
+	if ext == "" || len(ext) > 5 {
+		ext = ".ttf"
+	}
+
+	return GetProviderPath(
+		s.providerId,
+		"fonts",
+		utils.GetPathSafeName(data.Family.Name),
+		utils.GetPathSafeName(data.Variant.Name)+ext,
+	)
+}
+
+// Get returns the stored file for the variant, if we have an intact copy
+// downloaded from the variant's current url
+func (s *FontFileStore) Get(data *FontFamilyAndVariantData) ([]byte, bool) {
+	key := data.FontCacheKey()
+
+	// Copied so the file can be read and verified without holding the lock
+	s.mu.Lock()
+	stored, found := s.entries[key]
+	var entry fontStoreEntry
+	if found {
+		entry = *stored
+	}
+	s.mu.Unlock()
+
+	if !found {
+		return nil, false
+	}
+
+	if entry.SourceURL != data.Variant.DownloadURL {
+		s.remove(key)
+		return nil, false
+	}
+
+	fontData, err := os.ReadFile(entry.Path)
+	if err != nil {
+		logger.Warning("Failed to read stored font %s: %v", entry.Path, err)
+		s.remove(key)
+		return nil, false
+	}
+
+	if hashFontData(fontData) != entry.SHA256 {
+		logger.Warning("Stored font %s is corrupt, it will be downloaded again", entry.Path)
+		s.remove(key)
+		return nil, false
+	}
+
+	s.lru.Get(key)
+	err = s.updateIndex(func() {
+		if entry, found := s.entries[key]; found {
+			entry.LastAccess = time.Now()
+		}
+	})
+	if err != nil {
+		logger.Warning("Failed to save font store index for provider %s: %v", s.providerId, err)
+	}
+
+	return fontData, true
+}
+
+// Put stores the downloaded file, evicting the least recently used files when
+// the store goes over its size limit
+func (s *FontFileStore) Put(data *FontFamilyAndVariantData, fontData []byte) error {
+	key := data.FontCacheKey()
+	filePath := s.filePath(data)
+	size := int64(len(fontData))
+
+	if maxBytes := s.lru.MaxSize(); maxBytes > 0 && size > maxBytes {
+		return fmt.Errorf("font %s is larger than the font store limit", key)
+	}
+
+	if err := writeFileAtomic(filePath, fontData); err != nil {
+		return err
+	}
+
+	entry := &fontStoreEntry{
+		Path:       filePath,
+		SourceURL:  data.Variant.DownloadURL,
+		SHA256:     hashFontData(fontData),
+		Size:       size,
+		LastAccess: time.Now(),
+	}
+	s.mu.Lock()
+	s.entries[key] = entry
+	s.mu.Unlock()
+
+	// Evicts the least recently used files through remove
+	s.lru.Set(key, size)
+
+	return s.updateIndex(func() {})
+}
+
+// remove deletes the stored file and its index entry
+func (s *FontFileStore) remove(key string) {
+	s.lru.Remove(key)
+
+	var entry *fontStoreEntry
+	err := s.updateIndex(func() {
+		entry = s.entries[key]
+		delete(s.entries, key)
+	})
+	if err != nil {
+		logger.Warning("Failed to save font store index for provider %s: %v", s.providerId, err)
+	}
+	if entry == nil {
+		return
+	}
+
+	if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+		logger.Warning("Failed to remove stored font %s: %v", entry.Path, err)
+	}
+}
+
+// updateIndex applies update to the entries and saves the index
+func (s *FontFileStore) updateIndex(update func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update()
+
+	return saveCacheData(s.indexPath(), s.entries)
+}
+
+// writeFileAtomic writes to a temporary file first, so a crash never leaves a
+// half written font behind
+func writeFileAtomic(filePath string, data []byte) error {
+	if err := utils.EnsurePathExists(filePath); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(path.Dir(filePath), path.Base(filePath)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+func hashFontData(fontData []byte) string {
+	sum := sha256.Sum256(fontData)
+	return hex.EncodeToString(sum[:])
+}
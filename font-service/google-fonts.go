@@ -4,16 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"maps"
 	"net/http"
+	"net/url"
 	"os"
-	"slices"
 	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/schollz/progressbar/v3"
-	"github.com/tingtt/iterutil"
 	"github.com/wandb/parallel"
 
 	"GoogleFontsPluginApi/cache"
@@ -56,6 +54,21 @@ type webFontFamily struct {
 	Version      string                `json:"version"`
 	LastModified string                `json:"lastModified"`
 	Files        webFontFamilyFilesMap `json:"files"`
+	// Only returned when the variable font capability is requested
+	Axes []webFontAxis `json:"axes,omitempty"`
+}
+
+type webFontAxis struct {
+	Tag   string  `json:"tag"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// webFontVariableFamily is what the api returns about a family when asked
+// for variable fonts, the files are the variable font files
+type webFontVariableFamily struct {
+	Axes  []FontAxis
+	Files webFontFamilyFilesMap
 }
 
 type webFontListOriginal struct {
@@ -69,17 +82,61 @@ type webFontCachedItem struct {
 	Files       []webFontFamilyFile   `json:"files"`
 }
 
+const (
+	defaultGoogleFontsApiBaseURL     = "https://www.googleapis.com"
+	defaultGoogleFontsLicenseBaseURL = "https://raw.githubusercontent.com/google/fonts/refs/heads/main"
+)
+
+type GoogleFontsConfig struct {
+	// Used for every request the provider makes, defaults to http.DefaultClient
+	HttpClient *http.Client
+	ApiKey     string
+	// Base url of the webfonts api, defaults to https://www.googleapis.com
+	ApiBaseURL string
+	// Base url of the google/fonts repository the OFL license files are read from
+	LicenseBaseURL string
+	// When set, replaces the scheme and host of the font file urls returned by the api,
+	// so fonts can be downloaded from a mirror of fonts.gstatic.com
+	FontFilesBaseURL string
+}
+
+// GoogleFontsConfigFromEnv builds the config from GOOGLE_API_KEY, GOOGLE_FONTS_API_URL,
+// GOOGLE_FONTS_LICENSE_URL and GOOGLE_FONTS_FILES_URL
+func GoogleFontsConfigFromEnv() GoogleFontsConfig {
+	return GoogleFontsConfig{
+		ApiKey:           os.Getenv("GOOGLE_API_KEY"),
+		ApiBaseURL:       os.Getenv("GOOGLE_FONTS_API_URL"),
+		LicenseBaseURL:   os.Getenv("GOOGLE_FONTS_LICENSE_URL"),
+		FontFilesBaseURL: os.Getenv("GOOGLE_FONTS_FILES_URL"),
+	}
+}
+
 type GoogleFontsProvider struct {
 	cache      *cache.TTLCache[string, FontFamilyData]
-	categories []string
+	categories catalogCategories
+	config     GoogleFontsConfig
 }
 
-func (g *GoogleFontsProvider) GetCategories() []string { return g.categories }
+func (g *GoogleFontsProvider) GetCategories() []string { return g.categories.get() }
+
+func NewGoogleFontsProvider(config GoogleFontsConfig) IFontProvider {
+	if config.HttpClient == nil {
+		config.HttpClient = http.DefaultClient
+	}
+	if config.ApiBaseURL == "" {
+		config.ApiBaseURL = defaultGoogleFontsApiBaseURL
+	}
+	if config.LicenseBaseURL == "" {
+		config.LicenseBaseURL = defaultGoogleFontsLicenseBaseURL
+	}
+
+	config.ApiBaseURL = strings.TrimSuffix(config.ApiBaseURL, "/")
+	config.LicenseBaseURL = strings.TrimSuffix(config.LicenseBaseURL, "/")
+	config.FontFilesBaseURL = strings.TrimSuffix(config.FontFilesBaseURL, "/")
 
-func NewGoogleFontsProvider() IFontProvider {
 	return &GoogleFontsProvider{
-		cache:      cache.NewTTL[string, FontFamilyData](time.Hour * 24),
-		categories: []string{},
+		cache:  cache.NewTTL[string, FontFamilyData](0),
+		config: config,
 	}
 }
 
@@ -88,43 +145,41 @@ func (g *GoogleFontsProvider) GetDisplayName() string
 func (g *GoogleFontsProvider) GetFontCache() *cache.TTLCache[string, FontFamilyData] { return g.cache }
 
 func (g *GoogleFontsProvider) GetFonts(opts *GetFontsFilters) ([]FontFamilyData, error) {
-	filterIter := iterutil.FilterFunc(g.cache.Iterator(), func(data FontFamilyData) bool {
-		if opts != nil {
-			return opts.CanAddToResults(&data)
-		}
-		return true
-	})
-
-	items := slices.SortedFunc(filterIter, func(a, b FontFamilyData) int {
-		return a.Order.Popularity - b.Order.Popularity
-	})
-
-	return items, nil
+	return opts.Apply(g.cache.Iterator()), nil
 }
 func (g *GoogleFontsProvider) CacheFonts() ([]FontFamilyData, error) {
 
 	startedAt := time.Now()
 	defer func() { logger.Debug("[Google.CacheFonts]: %v", time.Since(startedAt)) }()
 
-	apiURL := "https://www.googleapis.com/webfonts/v1/webfonts?sort=popularity&key=" + os.Getenv("GOOGLE_API_KEY")
-	resp, err := http.Get(apiURL)
+	jsonData, err := g.fetchWebFonts("popularity", "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch fonts: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	bodyStr, err := io.ReadAll(resp.Body)
+	// The extra orderings are nice to have, fall back to the popularity order when they fail
+	trendingRanks, err := g.fetchWebFontRanks("trending")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		logger.Warning("Failed to fetch trending fonts, falling back to popularity: %v", err)
+	}
+	dateAddedRanks, err := g.fetchWebFontRanks("date")
+	if err != nil {
+		logger.Warning("Failed to fetch fonts by date added, falling back to popularity: %v", err)
+	}
+	// Without it every family is served as static, previews at custom axis values won't work
+	variableFamilies, err := g.fetchVariableFamilies()
+	if err != nil {
+		logger.Warning("Failed to fetch variable fonts, serving every family as static: %v", err)
 	}
 
-	var jsonData webFontListOriginal
-	if err := json.Unmarshal(bodyStr, &jsonData); err != nil {
-		return nil, err
+	// Never swap in an empty catalog, it would wipe out everything we're serving
+	if len(jsonData.Items) == 0 {
+		return nil, fmt.Errorf("webfonts api returned no fonts")
 	}
 
 	var uniqueCategories = make(map[string]bool)
 	var shortItems []FontFamilyData
+	var items = make(map[string]FontFamilyData, len(jsonData.Items))
 	for i, font := range jsonData.Items {
 		category := "unknown"
 		if font.Category != nil {
@@ -140,18 +195,25 @@ func (g *GoogleFontsProvider) CacheFonts() ([]FontFamilyData, error) {
 			HasLicense: true, // Set to true so it can be re-validated when we try to download the license
 			Order: FontFamilyOrderValues{
 				Popularity: i,
+				Trending:   rankOr(trendingRanks, font.Family, i),
+				DateAdded:  rankOr(dateAddedRanks, font.Family, i),
 			},
+			Subsets:      font.Subsets,
+			Version:      font.Version,
+			LastModified: font.LastModified,
+		}
+		if item.Subsets == nil {
+			item.Subsets = []string{}
 		}
 
-		createVariantPreviewObj := func(variantName string) VariantPreviewObject {
-			variantStr := strings.ReplaceAll(font.Family, " ", "%20") + ":" + variantName
-			text := strings.ReplaceAll(font.Family, " ", "%20")
+		variable, isVariable := variableFamilies[font.Family]
+		if isVariable {
+			item.Axes = variable.Axes
+		}
 
-			return VariantPreviewObject{
-				Template: fmt.Sprintf("/api/%s/fonts/preview?families=%s&resultType=png&small={IsSmall}&text={Text}", g.GetId(), variantStr),
-				Small:    fmt.Sprintf("/api/%s/fonts/preview?families=%s&resultType=png&small=true&text=%s", g.GetId(), variantStr, text),
-				Large:    fmt.Sprintf("/api/%s/fonts/preview?families=%s&resultType=png&small=false&text=%s", g.GetId(), variantStr, text),
-			}
+		// Keep what we already know about the license when refreshing
+		if previous, found := g.cache.Get(font.Family); found {
+			item.HasLicense = previous.HasLicense
 		}
 
 		hasRegular := false
@@ -161,7 +223,7 @@ func (g *GoogleFontsProvider) CacheFonts() ([]FontFamilyData, error) {
 				Name:        key,
 				FullName:    font.Family + ":" + key,
 				DownloadURL: url,
-				Preview:     createVariantPreviewObj(key),
+				Preview:     createVariantPreviewObj(g.GetId(), font.Family, key, item.Subsets),
 			})
 
 			if key == "regular" {
@@ -182,26 +244,132 @@ func (g *GoogleFontsProvider) CacheFonts() ([]FontFamilyData, error) {
 				Name:        "regular",
 				FullName:    font.Family + ":regular",
 				DownloadURL: font.Files["500"],
-				Preview:     createVariantPreviewObj("500"),
+				Preview:     createVariantPreviewObj(g.GetId(), font.Family, "500", item.Subsets),
 			})
 		}
 
+		if isVariable {
+			for i := range item.Variants {
+				item.Variants[i].VariableDownloadURL = variable.fileFor(item.Variants[i].Name)
+			}
+		}
+
 		item.Variants = sortVariants(item.Variants)
-		g.cache.Set(item.Name, item)
+		items[item.Name] = item
 
 		shortItems = append(shortItems, item)
 	}
 
-	g.categories = slices.Collect(maps.Keys(uniqueCategories))
+	g.cache.Replace(items)
+	g.categories.set(uniqueCategories)
 
 	return shortItems, nil
 }
+
+// fetchWebFonts lists the families in the given sort order, capability asks
+// for extra data like VF for variable fonts and may be empty
+func (g *GoogleFontsProvider) fetchWebFonts(sort, capability string) (*webFontListOriginal, error) {
+	query := url.Values{"key": {g.config.ApiKey}}
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	if capability != "" {
+		query.Set("capability", capability)
+	}
+
+	apiURL := g.config.ApiBaseURL + "/webfonts/v1/webfonts?" + query.Encode()
+	resp, err := g.config.HttpClient.Get(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch fonts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyStr, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr webFontApiErrorResponse
+		if err := json.Unmarshal(bodyStr, &apiErr); err == nil && apiErr.Error.Message != nil {
+			return nil, fmt.Errorf("failed to fetch fonts: %v: %s", resp.Status, *apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("failed to fetch fonts: %v", resp.Status)
+	}
+
+	jsonData := new(webFontListOriginal)
+	if err := json.Unmarshal(bodyStr, jsonData); err != nil {
+		return nil, err
+	}
+
+	return jsonData, nil
+}
+
+// fetchWebFontRanks returns the position of every family in the list sorted by the given sort
+func (g *GoogleFontsProvider) fetchWebFontRanks(sort string) (map[string]int, error) {
+	jsonData, err := g.fetchWebFonts(sort, "")
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[string]int, len(jsonData.Items))
+	for i, font := range jsonData.Items {
+		ranks[font.Family] = i
+	}
+
+	return ranks, nil
+}
+
+// fetchVariableFamilies returns the axes and variable font files of every
+// family that has a variable version
+func (g *GoogleFontsProvider) fetchVariableFamilies() (map[string]webFontVariableFamily, error) {
+	jsonData, err := g.fetchWebFonts("", "VF")
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string]webFontVariableFamily{}
+	for _, font := range jsonData.Items {
+		if len(font.Axes) == 0 {
+			continue
+		}
+
+		axes := make([]FontAxis, len(font.Axes))
+		for i, axis := range font.Axes {
+			axes[i] = FontAxis{Tag: axis.Tag, Min: axis.Start, Max: axis.End}
+		}
+
+		families[font.Family] = webFontVariableFamily{Axes: axes, Files: font.Files}
+	}
+
+	return families, nil
+}
+
+// fileFor returns the variable font file of the variant, italic variants use
+// the italic file when the family has one
+func (f webFontVariableFamily) fileFor(variant string) string {
+	if fileURL, found := f.Files[variant]; found {
+		return fileURL
+	}
+	if _, italic := variantWeightAndStyle(variant); italic && f.Files["italic"] != "" {
+		return f.Files["italic"]
+	}
+	return f.Files["regular"]
+}
+
+func rankOr(ranks map[string]int, family string, fallback int) int {
+	if rank, found := ranks[family]; found {
+		return rank
+	}
+	return fallback
+}
+
 func (g *GoogleFontsProvider) GetFontAndVariant(family, variant string) (*FontFamilyAndVariantData, error) {
 	data := new(FontFamilyAndVariantData)
 
 	fontData, found := g.cache.Get(family)
 	if !found {
-		return nil, fmt.Errorf("font family not found")
+		return nil, newFontError(ErrFontNotFound, nil, "font family %s not found", family)
 	}
 
 	data.Family = fontData
@@ -213,15 +381,64 @@ func (g *GoogleFontsProvider) GetFontAndVariant(family, variant string) (*FontFa
 		}
 	}
 
-	return nil, fmt.Errorf("variant not found")
+	return nil, newFontError(ErrVariantNotFound, nil, "variant %s not found in %s", variant, family)
 }
+func (g *GoogleFontsProvider) FetchFontData(variant FontFamilyVariant) ([]byte, error) {
+	fileURL, err := g.fontFileURL(variant.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.config.HttpClient.Get(fileURL)
+	if err != nil {
+		return nil, newFontError(ErrUpstreamUnavailable, err, "failed to download %s", variant.FullName)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, newFontError(ErrFontNotFound, nil, "font file for %s not found upstream", variant.FullName)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newFontError(ErrUpstreamUnavailable, nil, "failed to download %s: %v", variant.FullName, resp.Status)
+	}
+
+	fontData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newFontError(ErrUpstreamUnavailable, err, "failed to download %s", variant.FullName)
+	}
+
+	return fontData, nil
+}
+
+// fontFileURL points the download url at FontFilesBaseURL when a mirror is configured
+func (g *GoogleFontsProvider) fontFileURL(downloadURL string) (string, error) {
+	if g.config.FontFilesBaseURL == "" {
+		return downloadURL, nil
+	}
+
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid font download url %s: %w", downloadURL, err)
+	}
+
+	fileURL := g.config.FontFilesBaseURL + u.EscapedPath()
+	if u.RawQuery != "" {
+		fileURL += "?" + u.RawQuery
+	}
+
+	return fileURL, nil
+}
+
 func (g *GoogleFontsProvider) InitializeFromCache(data []FontFamilyData) {
 	uniqueCategories := make(map[string]bool)
 	for _, item := range data {
+		if item.Subsets == nil {
+			item.Subsets = []string{}
+		}
 		g.cache.Set(item.Name, item)
 		uniqueCategories[item.Category] = true
 	}
-	g.categories = slices.Collect(maps.Keys(uniqueCategories))
+	g.categories.set(uniqueCategories)
 }
 func getLicensePath(provider IFontProvider, fontName string) string {
 	return GetProviderPath(provider.GetId(), "fonts", utils.GetPathSafeName(fontName), "license.txt")
@@ -253,7 +470,7 @@ func loadMissingLicenses(provider IFontProvider, fonts []FontFamilyData) error {
 			defer bar.Add(1)
 			licensePath := getLicensePath(provider, font.Name)
 
-			license, err := downloadGoogleFontLicense(font, nil)
+			license, err := provider.DownloadLicense(font)
 			if err != nil {
 				return err
 			}
@@ -279,15 +496,11 @@ func loadMissingLicenses(provider IFontProvider, fonts []FontFamilyData) error {
 	return group.Wait()
 }
 
-func downloadGoogleFontLicense(font FontFamilyData, bar *progressbar.ProgressBar) (string, error) {
-	if bar != nil {
-		defer bar.Add(1)
-	}
-
+func (g *GoogleFontsProvider) DownloadLicense(font FontFamilyData) (string, error) {
 	fontName := utils.GetPathSafeName(font.Name)
-	url := fmt.Sprintf("https://raw.githubusercontent.com/google/fonts/refs/heads/main/ofl/%s/OFL.txt", fontName)
+	licenseURL := fmt.Sprintf("%s/ofl/%s/OFL.txt", g.config.LicenseBaseURL, fontName)
 
-	resp, err := http.Get(url)
+	resp, err := g.config.HttpClient.Get(licenseURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to get license file for %s: %w", font.Name, err)
 	}
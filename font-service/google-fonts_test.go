@@ -0,0 +1,110 @@
+package font_service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFetchWebFonts(t *testing.T) {
+	tests := []struct {
+		name       string
+		sort       string
+		capability string
+		status     int
+		body       string
+		wantQuery  url.Values
+		wantNames  []string
+		wantErr    string
+	}{
+		{
+			name:      "popularity",
+			sort:      "popularity",
+			status:    http.StatusOK,
+			body:      `{"kind":"webfonts#webfontList","items":[{"family":"Roboto","variants":["regular"]},{"family":"Open Sans","variants":["regular","700"]}]}`,
+			wantQuery: url.Values{"key": {"test-key"}, "sort": {"popularity"}},
+			wantNames: []string{"Roboto", "Open Sans"},
+		},
+		{
+			name:       "variable fonts",
+			capability: "VF",
+			status:     http.StatusOK,
+			body:       `{"kind":"webfonts#webfontList","items":[{"family":"Inter","axes":[{"tag":"wght","start":100,"end":900}]}]}`,
+			wantQuery:  url.Values{"key": {"test-key"}, "capability": {"VF"}},
+			wantNames:  []string{"Inter"},
+		},
+		{
+			name:      "api error with a message",
+			sort:      "alpha",
+			status:    http.StatusForbidden,
+			body:      `{"error":{"code":403,"message":"API key not valid"}}`,
+			wantQuery: url.Values{"key": {"test-key"}, "sort": {"alpha"}},
+			wantErr:   "403 Forbidden: API key not valid",
+		},
+		{
+			name:      "api error without a message",
+			status:    http.StatusBadGateway,
+			body:      `<html>bad gateway</html>`,
+			wantQuery: url.Values{"key": {"test-key"}},
+			wantErr:   "failed to fetch fonts: 502 Bad Gateway",
+		},
+		{
+			name:      "invalid json",
+			status:    http.StatusOK,
+			body:      `{"items":`,
+			wantQuery: url.Values{"key": {"test-key"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			var gotQuery url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotQuery = r.URL.Path, r.URL.Query()
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			provider := NewGoogleFontsProvider(GoogleFontsConfig{
+				HttpClient: server.Client(),
+				ApiKey:     "test-key",
+				ApiBaseURL: server.URL + "/",
+			}).(*GoogleFontsProvider)
+
+			list, err := provider.fetchWebFonts(tt.sort, tt.capability)
+
+			if gotPath != "/webfonts/v1/webfonts" {
+				t.Errorf("path = %q, want /webfonts/v1/webfonts", gotPath)
+			}
+			if gotQuery.Encode() != tt.wantQuery.Encode() {
+				t.Errorf("query = %q, want %q", gotQuery.Encode(), tt.wantQuery.Encode())
+			}
+
+			if tt.wantNames == nil {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, item := range list.Items {
+				names = append(names, item.Family)
+			}
+			if !slices.Equal(names, tt.wantNames) {
+				t.Errorf("families = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
@@ -0,0 +1,637 @@
+package font_service
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	gotext "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/font/opentype/tables"
+
+	"GoogleFontsPluginApi/cache"
+	"GoogleFontsPluginApi/logger"
+	"GoogleFontsPluginApi/utils"
+)
+
+// How long we wait after the last file system event before rescanning, so
+// copying a whole folder of fonts only triggers a single rescan.
+const localFontsRescanDelay = 500 * time.Millisecond
+
+// Entries of the name table that are read from local fonts
+const (
+	nameFontFamily         tables.NameID = 1
+	nameFontSubfamily      tables.NameID = 2
+	nameVersion            tables.NameID = 5
+	nameDesigner           tables.NameID = 9
+	nameLicense            tables.NameID = 13
+	namePreferredFamily    tables.NameID = 16
+	namePreferredSubfamily tables.NameID = 17
+)
+
+type localFontFile struct {
+	Path         string
+	Family       string
+	Subfamily    string
+	Designer     string
+	Weight       int
+	Italic       bool
+	License      string
+	Version      string
+	LastModified time.Time
+	Subsets      []string
+	// Variation axes, only variable fonts have them
+	Axes []FontAxis
+}
+
+func (f localFontFile) VariantName() string { return variantNameForWeight(f.Weight, f.Italic) }
+
+// LocalFontsProvider serves the .ttf/.otf files found in a directory on disk.
+// Clients download the files through the file endpoint, the paths on disk
+// never leave the server.
+type LocalFontsProvider struct {
+	cache      *cache.TTLCache[string, FontFamilyData]
+	categories catalogCategories
+
+	dir    string
+	scanMu sync.Mutex
+	// The registered provider, changes on disk are picked up through its catalog refresh
+	provider *FontProvider
+}
+
+func NewLocalFontsProvider(dir string) IFontProvider {
+	return &LocalFontsProvider{
+		cache: cache.NewTTL[string, FontFamilyData](0),
+		dir:   dir,
+	}
+}
+
+func (g *LocalFontsProvider) GetId() string                                         { return "local" }
+func (g *LocalFontsProvider) GetDisplayName() string                                { return "Local Fonts" }
+func (g *LocalFontsProvider) GetCategories() []string                               { return g.categories.get() }
+func (g *LocalFontsProvider) GetFontCache() *cache.TTLCache[string, FontFamilyData] { return g.cache }
+
+func (g *LocalFontsProvider) GetFonts(opts *GetFontsFilters) ([]FontFamilyData, error) {
+	return opts.Apply(g.cache.Iterator()), nil
+}
+
+func (g *LocalFontsProvider) CacheFonts() ([]FontFamilyData, error) {
+	g.scanMu.Lock()
+	defer g.scanMu.Unlock()
+
+	startedAt := time.Now()
+	defer func() { logger.Debug("[Local.CacheFonts]: %v", time.Since(startedAt)) }()
+
+	files, err := g.scanFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string][]localFontFile{}
+	for _, file := range files {
+		families[file.Family] = append(families[file.Family], file)
+	}
+
+	names := slices.Sorted(maps.Keys(families))
+	items := make([]FontFamilyData, 0, len(names))
+	for _, name := range names {
+		item, err := g.buildFamily(name, families[name])
+		if err != nil {
+			logger.Warning("Skipping local font family %s: %v", name, err)
+			continue
+		}
+
+		item.Order.Popularity = len(items)
+		item.Order.Trending = len(items)
+		items = append(items, item)
+	}
+
+	// There's no date added for local files, the newest files are the closest thing to it
+	byDate := slices.Clone(items)
+	slices.SortStableFunc(byDate, func(a, b FontFamilyData) int {
+		return strings.Compare(b.LastModified, a.LastModified)
+	})
+	for rank, f := range byDate {
+		items[f.Order.Popularity].Order.DateAdded = rank
+	}
+
+	g.setFamilies(items)
+
+	return items, nil
+}
+
+func (g *LocalFontsProvider) GetFontAndVariant(family, variant string) (*FontFamilyAndVariantData, error) {
+	fontData, found := g.cache.Get(family)
+	if !found {
+		return nil, newFontError(ErrFontNotFound, nil, "font family %s not found", family)
+	}
+
+	for _, v := range fontData.Variants {
+		if v.Name == variant {
+			return &FontFamilyAndVariantData{Family: fontData, Variant: v}, nil
+		}
+	}
+
+	return nil, newFontError(ErrVariantNotFound, nil, "variant %s not found in %s", variant, family)
+}
+
+func (g *LocalFontsProvider) FetchFontData(variant FontFamilyVariant) ([]byte, error) {
+	fontData, err := os.ReadFile(variant.FilePath)
+	if os.IsNotExist(err) {
+		return nil, newFontError(ErrFontNotFound, err, "font file for %s not found", variant.FullName)
+	}
+	if err != nil {
+		return nil, newFontError(ErrUpstreamUnavailable, err, "failed to read %s", variant.FullName)
+	}
+
+	return fontData, nil
+}
+
+// DownloadLicense has nothing to download, licenses are read from the font's
+// name table and written to disk while scanning
+func (g *LocalFontsProvider) DownloadLicense(font FontFamilyData) (string, error) {
+	return "", nil
+}
+
+// InitializeFromCache loads the previous catalog, the files on disk are the
+// source of truth so they're rescanned by watchCatalog once the provider is
+// registered, which records what changed while we weren't running
+func (g *LocalFontsProvider) InitializeFromCache(data []FontFamilyData) {
+	g.setFamilies(data)
+}
+
+func (g *LocalFontsProvider) setFamilies(data []FontFamilyData) {
+	items := make(map[string]FontFamilyData, len(data))
+	uniqueCategories := make(map[string]bool)
+	for _, item := range data {
+		if item.Subsets == nil {
+			item.Subsets = []string{}
+		}
+		items[item.Name] = item
+		uniqueCategories[item.Category] = true
+	}
+
+	g.cache.Replace(items)
+	g.categories.set(uniqueCategories)
+}
+
+func (g *LocalFontsProvider) buildFamily(name string, files []localFontFile) (FontFamilyData, error) {
+	item := FontFamilyData{
+		Name:     name,
+		Category: "unknown",
+		Variants: []FontFamilyVariant{},
+	}
+
+	seen := map[string]bool{}
+	var lastModified time.Time
+	var regularFallback *localFontFile
+
+	// Static variants are rendered with the variable file of the same style
+	// when they're previewed at custom axis values
+	variableFiles := map[bool]localFontFile{}
+	for _, file := range files {
+		if len(file.Axes) == 0 {
+			continue
+		}
+		item.Axes = mergeFontAxes(item.Axes, file.Axes)
+		if _, found := variableFiles[file.Italic]; !found {
+			variableFiles[file.Italic] = file
+		}
+	}
+
+	// The preview urls depend on the subsets, so they're known before the variants
+	subsets := map[string]bool{}
+	for _, file := range files {
+		for _, subset := range file.Subsets {
+			subsets[subset] = true
+		}
+	}
+	item.Subsets = slices.Sorted(maps.Keys(subsets))
+
+	for i, file := range files {
+		if item.Designer == "" {
+			item.Designer = file.Designer
+		}
+		if file.LastModified.After(lastModified) {
+			lastModified = file.LastModified
+			item.Version = file.Version
+		}
+
+		variantName := file.VariantName()
+		if seen[variantName] {
+			logger.Warning("Duplicate local font variant %s:%s in %s", name, variantName, file.Path)
+			continue
+		}
+		seen[variantName] = true
+
+		item.Variants = append(item.Variants, g.createVariant(item, variantName, variantName, file, variableFiles))
+
+		if !file.Italic && (regularFallback == nil || absInt(file.Weight-400) < absInt(regularFallback.Weight-400)) {
+			regularFallback = &files[i]
+		}
+
+		if file.License != "" && !item.HasLicense {
+			if err := g.writeLicense(name, file.License); err != nil {
+				logger.Warning("Failed to write license for local font %s: %v", name, err)
+			} else {
+				item.HasLicense = true
+			}
+		}
+	}
+
+	// Mirror the google provider, which always exposes a "regular" variant
+	if !seen["regular"] {
+		if regularFallback == nil {
+			return item, fmt.Errorf("no upright variants found")
+		}
+
+		item.Variants = append(item.Variants, g.createVariant(item, "regular", regularFallback.VariantName(), *regularFallback, variableFiles))
+	}
+
+	item.Variants = sortVariants(item.Variants)
+	item.LastModified = lastModified.UTC().Format(time.DateOnly)
+
+	return item, nil
+}
+
+// createVariant builds the variant of the file, variableFiles are the
+// family's variable files by whether they're italic
+func (g *LocalFontsProvider) createVariant(
+	family FontFamilyData,
+	variantName, previewVariant string,
+	file localFontFile,
+	variableFiles map[bool]localFontFile,
+) FontFamilyVariant {
+	variable, found := variableFiles[file.Italic]
+	if len(file.Axes) > 0 {
+		variable, found = file, true
+	} else if !found {
+		variable, found = variableFiles[!file.Italic]
+	}
+
+	variant := FontFamilyVariant{
+		Name:        variantName,
+		FullName:    family.Name + ":" + variantName,
+		DownloadURL: createFontFileURL(g.GetId(), family.Name, variantName, false),
+		Preview:     createVariantPreviewObj(g.GetId(), family.Name, previewVariant, family.Subsets),
+		FilePath:    file.Path,
+	}
+	if found {
+		variant.VariableDownloadURL = createFontFileURL(g.GetId(), family.Name, variantName, true)
+		variant.VariableFilePath = variable.Path
+	}
+
+	return variant
+}
+
+// mergeFontAxes adds the axes of another variable file of the family, axes
+// both files have cover the range of both
+func mergeFontAxes(axes, other []FontAxis) []FontAxis {
+	for _, axis := range other {
+		i := slices.IndexFunc(axes, func(a FontAxis) bool { return a.Tag == axis.Tag })
+		if i == -1 {
+			axes = append(axes, axis)
+			continue
+		}
+		axes[i].Min = min(axes[i].Min, axis.Min)
+		axes[i].Max = max(axes[i].Max, axis.Max)
+	}
+	return axes
+}
+
+func (g *LocalFontsProvider) writeLicense(family, license string) error {
+	licensePath := getLicensePath(g, family)
+	if err := utils.EnsurePathExists(licensePath); err != nil {
+		return err
+	}
+
+	return os.WriteFile(licensePath, []byte(license), 0644)
+}
+
+func (g *LocalFontsProvider) scanFiles() ([]localFontFile, error) {
+	var files []localFontFile
+
+	err := filepath.WalkDir(g.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isLocalFontFile(p) {
+			return nil
+		}
+
+		file, err := readLocalFontFile(p)
+		if err != nil {
+			logger.Warning("Skipping local font %s: %v", p, err)
+			return nil
+		}
+
+		files = append(files, file)
+		return nil
+	})
+
+	return files, err
+}
+
+// rescan refreshes the catalog the same way the scheduled refresh does, so
+// changes on disk show up in the catalog diff and history. The parsed fonts of
+// every family are dropped, since files can be replaced without the family
+// counting as updated.
+func (g *LocalFontsProvider) rescan() {
+	previous := g.cache.All()
+
+	diff, err := g.provider.RefreshCatalog()
+	if err != nil {
+		logger.Error("Failed to rescan local fonts in %s: %v", g.dir, err)
+		return
+	}
+
+	for _, family := range append(previous, g.cache.All()...) {
+		FontProviders.ForgetParsedFonts(g.GetId(), family)
+	}
+
+	logger.Info(
+		"Rescanned local fonts in %s: %d added, %d removed, %d updated",
+		g.dir, len(diff.Added), len(diff.Removed), len(diff.Updated),
+	)
+}
+
+// watchCatalog rescans the files changed while we weren't running, then keeps
+// watching the directory for changes
+func (g *LocalFontsProvider) watchCatalog(provider *FontProvider) {
+	g.provider = provider
+	g.rescan()
+
+	go g.watch()
+}
+
+func (g *LocalFontsProvider) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error("Failed to watch local fonts in %s: %v", g.dir, err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := addWatchDirs(watcher, g.dir); err != nil {
+		logger.Error("Failed to watch local fonts in %s: %v", g.dir, err)
+		return
+	}
+
+	var debounce *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatchDirs(watcher, event.Name); err != nil {
+						logger.Warning("Failed to watch %s: %v", event.Name, err)
+					}
+				}
+			}
+
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(localFontsRescanDelay, g.rescan)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warning("Local fonts watcher error: %v", err)
+		}
+	}
+}
+
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+func isLocalFontFile(p string) bool {
+	ext := strings.ToLower(filepath.Ext(p))
+	return ext == ".ttf" || ext == ".otf"
+}
+
+func readLocalFontFile(p string) (localFontFile, error) {
+	file := localFontFile{}
+
+	absPath, err := filepath.Abs(p)
+	if err != nil {
+		return file, err
+	}
+	file.Path = absPath
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return file, err
+	}
+	file.LastModified = info.ModTime()
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return file, err
+	}
+
+	ld, err := ot.NewLoader(bytes.NewReader(data))
+	if err != nil {
+		return file, err
+	}
+
+	// Previews are rendered with the same parser, so fonts it can't load are skipped
+	ft, err := gotext.NewFont(ld)
+	if err != nil {
+		return file, err
+	}
+
+	names := readNameTable(ld)
+	file.Family = firstNonEmpty(names.Name(namePreferredFamily), names.Name(nameFontFamily))
+	file.Subfamily = firstNonEmpty(names.Name(namePreferredSubfamily), names.Name(nameFontSubfamily))
+	file.License = names.Name(nameLicense)
+	file.Designer = names.Name(nameDesigner)
+	file.Version = names.Name(nameVersion)
+	file.Subsets = detectSubsets(ft)
+	file.Axes = readFontAxes(ld)
+
+	if file.Family == "" {
+		return file, fmt.Errorf("font has no family name")
+	}
+
+	weight, italic, found := readOS2WeightAndStyle(ld)
+	if !found {
+		weight, italic = weightAndStyleFromSubfamily(file.Subfamily)
+	}
+
+	file.Weight = normalizeWeight(weight)
+	file.Italic = italic || strings.Contains(strings.ToLower(file.Subfamily), "italic")
+
+	return file, nil
+}
+
+// readNameTable reads the name table, which gotext.Font doesn't expose. A
+// font without one has no names.
+func readNameTable(ld *ot.Loader) tables.Name {
+	raw, err := ld.RawTable(ot.MustNewTag("name"))
+	if err != nil {
+		return tables.Name{}
+	}
+
+	names, _, err := tables.ParseName(raw)
+	if err != nil {
+		return tables.Name{}
+	}
+	return names
+}
+
+// readOS2WeightAndStyle reads usWeightClass and the italic bit of fsSelection
+// from the OS/2 table, which gotext.Font doesn't expose.
+func readOS2WeightAndStyle(ld *ot.Loader) (int, bool, bool) {
+	raw, err := ld.RawTable(ot.MustNewTag("OS/2"))
+	if err != nil {
+		return 0, false, false
+	}
+
+	os2, _, err := tables.ParseOs2(raw)
+	if err != nil {
+		return 0, false, false
+	}
+
+	return int(os2.USWeightClass), os2.FsSelection&1 != 0, true
+}
+
+// readFontAxes reads the variation axes from the fvar table, which
+// gotext.Font doesn't expose. Static fonts have none.
+func readFontAxes(ld *ot.Loader) []FontAxis {
+	raw, err := ld.RawTable(ot.MustNewTag("fvar"))
+	if err != nil {
+		return nil
+	}
+
+	fvar, _, err := tables.ParseFvar(raw)
+	if err != nil {
+		return nil
+	}
+
+	var axes []FontAxis
+	for _, axis := range fvar.Axis {
+		axes = append(axes, FontAxis{
+			Tag: axis.Tag.String(),
+			Min: float64(axis.Minimum),
+			Max: float64(axis.Maximum),
+		})
+	}
+	return axes
+}
+
+// Characters a font has to contain to be considered as supporting a subset
+var subsetSampleRunes = []struct {
+	subset string
+	runes  []rune
+}{
+	{"latin", []rune("AZaz09")},
+	{"latin-ext", []rune("ĀłŐűŸ")},
+	{"cyrillic", []rune("АЯаяЖж")},
+	{"cyrillic-ext", []rune("ҐґҚқ")},
+	{"greek", []rune("ΑΩαωΣσ")},
+	{"greek-ext", []rune("ἀἈὠ")},
+	{"vietnamese", []rune("ƠơƯưạ")},
+	{"hebrew", []rune("אבגש")},
+	{"arabic", []rune("ابتع")},
+	{"devanagari", []rune("अकमह")},
+	{"thai", []rune("กขคฮ")},
+	{"japanese", []rune("あいアイ")},
+	{"korean", []rune("가나한")},
+	{"chinese-simplified", []rune("中国们")},
+}
+
+func detectSubsets(ft *gotext.Font) []string {
+	subsets := []string{}
+	for _, s := range subsetSampleRunes {
+		if slices.IndexFunc(s.runes, func(r rune) bool { return !hasGlyph(ft, r) }) == -1 {
+			subsets = append(subsets, s.subset)
+		}
+	}
+	return subsets
+}
+
+func hasGlyph(ft *gotext.Font, r rune) bool {
+	_, found := ft.NominalGlyph(r)
+	return found
+}
+
+var subfamilyWeights = []struct {
+	keyword string
+	weight  int
+}{
+	{"extralight", 200},
+	{"ultralight", 200},
+	{"semibold", 600},
+	{"demibold", 600},
+	{"extrabold", 800},
+	{"ultrabold", 800},
+	{"thin", 100},
+	{"light", 300},
+	{"medium", 500},
+	{"bold", 700},
+	{"black", 900},
+	{"heavy", 900},
+}
+
+func weightAndStyleFromSubfamily(subfamily string) (int, bool) {
+	name := strings.ReplaceAll(strings.ToLower(subfamily), " ", "")
+	italic := strings.Contains(name, "italic") || strings.Contains(name, "oblique")
+
+	for _, w := range subfamilyWeights {
+		if strings.Contains(name, w.keyword) {
+			return w.weight, italic
+		}
+	}
+
+	return 400, italic
+}
+
+// normalizeWeight snaps a usWeightClass value onto the 100-900 scale
+func normalizeWeight(weight int) int {
+	if weight <= 0 {
+		return 400
+	}
+
+	weight = (weight + 50) / 100 * 100
+
+	return min(max(weight, 100), 900)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
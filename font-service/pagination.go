@@ -0,0 +1,113 @@
+package font_service
+
+import (
+	b64 "encoding/base64"
+	"slices"
+
+	"github.com/goccy/go-json"
+)
+
+type FontsPageOptions struct {
+	Offset int `query:"offset"`
+	// Number of fonts per page, 0 returns everything after the offset
+	Limit int `query:"limit"`
+	// Cursor returned as nextCursor by the previous page, takes precedence over offset
+	Cursor string `query:"cursor"`
+}
+
+type FontsPage struct {
+	Items      []FontFamilyData `json:"items"`
+	Total      int              `json:"total"`
+	Offset     int              `json:"offset"`
+	Limit      int              `json:"limit"`
+	NextCursor *string          `json:"nextCursor"`
+}
+
+// fontsCursor is encoded into the opaque cursor string. We store the name of
+// the last font on the page so the next page starts right after it, even when
+// the catalog was refreshed in between. The offset is only used as a fallback
+// when that font no longer exists. The sort key makes sure a cursor is only
+// used with the ordering it was created for.
+type fontsCursor struct {
+	Sort   string `json:"s"`
+	After  string `json:"a"`
+	Offset int    `json:"o"`
+}
+
+func encodeFontsCursor(cursor fontsCursor) string {
+	data, _ := json.Marshal(cursor)
+	return b64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFontsCursor(value string) (fontsCursor, error) {
+	var cursor fontsCursor
+
+	data, err := b64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, newFontError(ErrInvalidOptions, nil, "invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, newFontError(ErrInvalidOptions, nil, "invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+func (o *FontsPageOptions) Validate() error {
+	if o.Offset < 0 {
+		return newFontError(ErrInvalidOptions, nil, "offset must not be negative")
+	}
+	if o.Limit < 0 {
+		return newFontError(ErrInvalidOptions, nil, "limit must not be negative")
+	}
+	return nil
+}
+
+// PaginateFonts returns a page of the fonts, which are already sorted in the
+// order identified by sortKey
+func PaginateFonts(items []FontFamilyData, opts *FontsPageOptions, sortKey string) (*FontsPage, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	start := opts.Offset
+	if opts.Cursor != "" {
+		cursor, err := decodeFontsCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if cursor.Sort != sortKey {
+			return nil, newFontError(ErrInvalidOptions, nil, "cursor was created for sort %s, not %s", cursor.Sort, sortKey)
+		}
+
+		start = cursor.Offset
+		if i := slices.IndexFunc(items, func(f FontFamilyData) bool { return f.Name == cursor.After }); i != -1 {
+			start = i + 1
+		}
+	}
+	start = min(start, len(items))
+
+	end := len(items)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, len(items))
+	}
+
+	page := &FontsPage{
+		Items:  items[start:end],
+		Total:  len(items),
+		Offset: start,
+		Limit:  opts.Limit,
+	}
+
+	if end < len(items) && end > start {
+		next := encodeFontsCursor(fontsCursor{
+			Sort:   sortKey,
+			After:  items[end-1].Name,
+			Offset: end,
+		})
+		page.NextCursor = &next
+	}
+
+	return page, nil
+}
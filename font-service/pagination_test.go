@@ -0,0 +1,124 @@
+package font_service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func fontsNamed(names ...string) []FontFamilyData {
+	items := make([]FontFamilyData, len(names))
+	for i, name := range names {
+		items[i] = FontFamilyData{Name: name}
+	}
+	return items
+}
+
+func fontNames(items []FontFamilyData) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+func TestPaginateFontsCursorRoundTrip(t *testing.T) {
+	items := fontsNamed("A", "B", "C", "D", "E")
+
+	tests := []struct {
+		name  string
+		limit int
+		want  [][]string
+	}{
+		{name: "uneven pages", limit: 2, want: [][]string{{"A", "B"}, {"C", "D"}, {"E"}}},
+		{name: "even pages", limit: 5, want: [][]string{{"A", "B", "C", "D", "E"}}},
+		{name: "single items", limit: 1, want: [][]string{{"A"}, {"B"}, {"C"}, {"D"}, {"E"}}},
+		{name: "no limit", limit: 0, want: [][]string{{"A", "B", "C", "D", "E"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &FontsPageOptions{Limit: tt.limit}
+
+			var pages [][]string
+			for range len(items) + 1 {
+				page, err := PaginateFonts(items, opts, "name")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if page.Total != len(items) {
+					t.Errorf("total = %d, want %d", page.Total, len(items))
+				}
+
+				pages = append(pages, fontNames(page.Items))
+				if page.NextCursor == nil {
+					break
+				}
+				opts = &FontsPageOptions{Limit: tt.limit, Cursor: *page.NextCursor}
+			}
+
+			if !slices.EqualFunc(pages, tt.want, slices.Equal) {
+				t.Errorf("pages = %v, want %v", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginateFontsCursorAfterRefresh(t *testing.T) {
+	first, err := PaginateFonts(fontsNamed("A", "B", "C", "D"), &FontsPageOptions{Limit: 2}, "name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cursor := *first.NextCursor
+
+	tests := []struct {
+		name  string
+		items []FontFamilyData
+		want  []string
+	}{
+		{name: "font added before the cursor", items: fontsNamed("A", "AA", "B", "C", "D"), want: []string{"C", "D"}},
+		// The last font of the page is gone, so the page continues at its offset
+		{name: "last font of the page removed", items: fontsNamed("A", "C", "D", "E"), want: []string{"D", "E"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := PaginateFonts(tt.items, &FontsPageOptions{Limit: 2, Cursor: cursor}, "name")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := fontNames(page.Items); !slices.Equal(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginateFontsInvalidOptions(t *testing.T) {
+	items := fontsNamed("A", "B", "C")
+
+	first, err := PaginateFonts(items, &FontsPageOptions{Limit: 1}, "name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    FontsPageOptions
+		sortKey string
+	}{
+		{name: "cursor of another sort", opts: FontsPageOptions{Limit: 1, Cursor: *first.NextCursor}, sortKey: "popularity"},
+		{name: "malformed cursor", opts: FontsPageOptions{Cursor: "not a cursor"}, sortKey: "name"},
+		{name: "negative offset", opts: FontsPageOptions{Offset: -1}, sortKey: "name"},
+		{name: "negative limit", opts: FontsPageOptions{Limit: -1}, sortKey: "name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PaginateFonts(items, &tt.opts, tt.sortKey)
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("error = %v, want an invalid options error", err)
+			}
+		})
+	}
+}
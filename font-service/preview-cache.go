@@ -0,0 +1,397 @@
+package font_service
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/sync/singleflight"
+
+	"GoogleFontsPluginApi/cache"
+	"GoogleFontsPluginApi/logger"
+	"GoogleFontsPluginApi/utils"
+)
+
+const (
+	defaultPreviewCacheMaxMB     = 64
+	defaultPreviewDiskCacheMaxMB = 0
+	defaultPreviewCacheMaxAge    = 24 * time.Hour
+
+	// Bump this when a rendering change alters the output for the same options,
+	// so previews and etags from before the change aren't reused
+	previewRenderVersion = 3
+
+	previewCacheFileExt = ".preview"
+)
+
+var (
+	// Makes concurrent requests for the same preview render it only once
+	previewRenderGroup singleflight.Group
+
+	getPreviewCache = sync.OnceValue(func() *PreviewCache {
+		return NewPreviewCache(GetPreviewCacheMaxBytes(), GetPreviewDiskCacheMaxBytes(), path.Join("data", "previews"))
+	})
+)
+
+// GetPreviewCacheMaxBytes reads PREVIEW_CACHE_MAX_MB, the size of the in
+// memory preview cache, 0 disables it
+func GetPreviewCacheMaxBytes() int64 {
+	return int64(utils.GetEnvInt("PREVIEW_CACHE_MAX_MB", defaultPreviewCacheMaxMB)) * 1024 * 1024
+}
+
+// GetPreviewDiskCacheMaxBytes reads PREVIEW_DISK_CACHE_MAX_MB, the size of the
+// on disk preview cache, it's disabled by default
+func GetPreviewDiskCacheMaxBytes() int64 {
+	return int64(utils.GetEnvInt("PREVIEW_DISK_CACHE_MAX_MB", defaultPreviewDiskCacheMaxMB)) * 1024 * 1024
+}
+
+// GetPreviewCacheControl returns the Cache-Control header for previews, the
+// max age is read from PREVIEW_CACHE_MAX_AGE. With a max age of 0 clients
+// have to revalidate every time using the ETag.
+func GetPreviewCacheControl() string {
+	maxAge := utils.GetEnvDuration("PREVIEW_CACHE_MAX_AGE", defaultPreviewCacheMaxAge)
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+}
+
+// PreparedFontPreview is a preview with its font and options resolved which
+// hasn't been rendered yet. Key identifies the rendered output, so caches and
+// etags can be checked without rendering anything.
+type PreparedFontPreview struct {
+	Key string
+
+	provider IFontProvider
+	options  *CreateFontPreviewOptions
+	data     *FontFamilyAndVariantData
+}
+
+func PrepareFontPreview(
+	provider IFontProvider,
+	r *CreateFontPreviewOptions,
+	familyData FontAndVariant,
+) (*PreparedFontPreview, error) {
+	data, err := provider.GetFontAndVariant(familyData.Family, familyData.Variant)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := previewCacheKey(provider, r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PreparedFontPreview{
+		Key:      key,
+		provider: provider,
+		options:  r,
+		data:     data,
+	}, nil
+}
+
+// previewCacheKey hashes everything the rendered output depends on, the
+// options are normalized first so equivalent options share a key. The font
+// version and url are included so updated fonts get new previews.
+func previewCacheKey(provider IFontProvider, r *CreateFontPreviewOptions, data *FontFamilyAndVariantData) (string, error) {
+	size, err := r.size()
+	if err != nil {
+		return "", err
+	}
+	style, err := r.style()
+	if err != nil {
+		return "", err
+	}
+	variations, err := r.variations(data)
+	if err != nil {
+		return "", err
+	}
+
+	// Raw and base64 results share the rendered output
+	output := fmt.Sprintf("%s:%d", cmp.Or(r.Format, PreviewFormatPng), r.quality())
+	if r.ResultType == FontPreviewResultTypeSvg {
+		output = string(FontPreviewResultTypeSvg)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n", previewRenderVersion, provider.GetId())
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s\n", data.Family.Name, data.Family.Version, data.Family.LastModified, data.Variant.Name, data.Variant.DownloadURL)
+	fmt.Fprintf(h, "%s\n%q\n%+v\n%+v\n", output, r.text(), size, style)
+	// Without a text the text depends on the family's subsets, see defaultPreviewText
+	if r.text() == "" {
+		fmt.Fprintf(h, "%q\n", data.Family.Subsets)
+	}
+	// Local files can be replaced without their url or date changing
+	fmt.Fprintf(h, "%s\n", localFileStamp(data.Variant.FilePath))
+	if len(variations) > 0 {
+		variant := data.Variant
+		fmt.Fprintf(h, "%s\n%s\n%+v\n", variant.VariableDownloadURL, localFileStamp(variant.VariableFilePath), variations)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// localFileStamp identifies the version of a local font file by its size and
+// modification time, fonts without a file on disk have none
+func localFileStamp(filePath string) string {
+	if filePath == "" {
+		return ""
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// ETag is a strong etag of the response, it differs per result type as base64
+// and raw responses share the rendered output but not the response body
+func (p *PreparedFontPreview) ETag() string {
+	return `"` + p.Key + "-" + string(p.options.ResultType) + `"`
+}
+
+// RenderedPreview is a rendered preview and the coverage of its text, they're
+// cached together so cached previews never have to load the font
+type RenderedPreview struct {
+	Data     []byte
+	Coverage FontCoverage
+}
+
+// size is roughly how much memory the preview takes up
+func (p *RenderedPreview) size() int64 {
+	size := len(p.Data) + len(p.Coverage.Text)
+	for _, r := range p.Coverage.Missing {
+		size += len(r)
+	}
+	return int64(size)
+}
+
+// Render returns the rendered preview from the cache, or renders and caches
+// it. The returned preview is shared and must not be modified.
+func (p *PreparedFontPreview) Render() (*RenderedPreview, error) {
+	previewCache := getPreviewCache()
+	if preview, found := previewCache.Get(p.Key); found {
+		return preview, nil
+	}
+
+	preview, err, _ := previewRenderGroup.Do(p.Key, func() (any, error) {
+		// Another request may have rendered it while we were waiting to get here
+		if preview, found := previewCache.Get(p.Key); found {
+			return preview, nil
+		}
+
+		preview, err := renderFontPreview(p.provider, p.options, p.data)
+		if err != nil {
+			return nil, err
+		}
+
+		previewCache.Put(p.Key, preview)
+		return preview, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return preview.(*RenderedPreview), nil
+}
+
+// PreviewCache keeps rendered previews in memory, and optionally on disk under
+// data/previews so they survive restarts. Both tiers evict the least recently
+// used previews once they go over their size limit, a tier with a limit of 0
+// is disabled. Files on disk start with the coverage as a line of json,
+// followed by the rendered data.
+type PreviewCache struct {
+	memory *cache.LRUCache[string, *RenderedPreview]
+	// Key -> file size of the previews stored on disk
+	disk *cache.LRUCache[string, int64]
+	dir  string
+}
+
+func NewPreviewCache(memoryMaxBytes, diskMaxBytes int64, dir string) *PreviewCache {
+	c := &PreviewCache{dir: dir}
+
+	if memoryMaxBytes > 0 {
+		c.memory = cache.NewLRU[string, *RenderedPreview](memoryMaxBytes, (*RenderedPreview).size)
+	}
+
+	if diskMaxBytes > 0 {
+		c.disk = cache.NewLRU[string, int64](diskMaxBytes, func(size int64) int64 { return size })
+		c.disk.OnEvict(func(key string, _ int64) { c.removeFile(key) })
+		c.loadDiskIndex()
+	}
+
+	return c
+}
+
+func (c *PreviewCache) filePath(key string) string {
+	return path.Join(c.dir, key[:2], key+previewCacheFileExt)
+}
+
+// loadDiskIndex adds the previews stored by earlier runs, oldest first so the
+// most recently used end up at the front. Reads update a file's modification
+// time so it can be used as the last access time.
+func (c *PreviewCache) loadDiskIndex() {
+	type storedPreview struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+
+	var stored []storedPreview
+	_ = filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		// Left behind by a write that never finished
+		if strings.HasSuffix(p, ".tmp") {
+			_ = os.Remove(p)
+			return nil
+		}
+
+		key, found := strings.CutSuffix(d.Name(), previewCacheFileExt)
+		if !found {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		stored = append(stored, storedPreview{key, info.Size(), info.ModTime()})
+		return nil
+	})
+
+	slices.SortFunc(stored, func(a, b storedPreview) int { return a.modTime.Compare(b.modTime) })
+
+	for _, preview := range stored {
+		if !c.disk.Set(preview.key, preview.size) {
+			c.removeFile(preview.key)
+		}
+	}
+}
+
+func (c *PreviewCache) Get(key string) (*RenderedPreview, bool) {
+	if c.memory != nil {
+		if preview, found := c.memory.Get(key); found {
+			return preview, true
+		}
+	}
+
+	if c.disk == nil {
+		return nil, false
+	}
+
+	if _, found := c.disk.Get(key); !found {
+		return nil, false
+	}
+
+	filePath := c.filePath(key)
+	preview, err := readPreviewFile(filePath)
+	if err != nil {
+		logger.Warning("Failed to read cached preview %s: %v", filePath, err)
+		c.disk.Remove(key)
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(filePath, now, now)
+
+	if c.memory != nil {
+		c.memory.Set(key, preview)
+	}
+
+	return preview, true
+}
+
+func (c *PreviewCache) Put(key string, preview *RenderedPreview) {
+	if c.memory != nil {
+		c.memory.Set(key, preview)
+	}
+
+	if c.disk != nil {
+		size, err := c.writeFile(key, preview)
+		if err != nil {
+			logger.Warning("Failed to store preview %s on disk: %v", key, err)
+			return
+		}
+		if !c.disk.Set(key, size) {
+			c.removeFile(key)
+		}
+	}
+}
+
+func readPreviewFile(filePath string) (*RenderedPreview, error) {
+	file, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage, data, found := bytes.Cut(file, []byte("\n"))
+	if !found {
+		return nil, fmt.Errorf("preview has no coverage line")
+	}
+
+	preview := &RenderedPreview{Data: data}
+	if err := json.Unmarshal(coverage, &preview.Coverage); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// writeFile stores the preview on disk and returns the size of its file
+func (c *PreviewCache) writeFile(key string, preview *RenderedPreview) (int64, error) {
+	coverage, err := json.Marshal(preview.Coverage)
+	if err != nil {
+		return 0, err
+	}
+	data := slices.Concat(coverage, []byte("\n"), preview.Data)
+
+	filePath := c.filePath(key)
+	if err := utils.EnsurePathExists(filePath); err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first, so a crash never leaves a half written preview behind
+	tmp, err := os.CreateTemp(path.Dir(filePath), key+"-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return 0, err
+	}
+
+	return int64(len(data)), nil
+}
+
+func (c *PreviewCache) removeFile(key string) {
+	if err := os.Remove(c.filePath(key)); err != nil && !os.IsNotExist(err) {
+		logger.Warning("Failed to remove cached preview %s: %v", key, err)
+	}
+}
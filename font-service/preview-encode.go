@@ -0,0 +1,80 @@
+package font_service
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// PreviewFormat is the image format raster previews are encoded in
+type PreviewFormat string
+
+const (
+	PreviewFormatPng PreviewFormat = "png"
+	// Lossless webp, usually a good deal smaller than png. The encoder has no
+	// lossy mode so it takes no quality.
+	PreviewFormatWebp PreviewFormat = "webp"
+	// Lossy and without transparency, it's flattened onto the background, or
+	// white when the background is transparent
+	PreviewFormatJpeg PreviewFormat = "jpeg"
+
+	defaultPreviewQuality = 90
+)
+
+// PreviewFormatContentTypes maps content types to formats, in order of
+// preference when negotiating a format
+var PreviewFormatContentTypes = []struct {
+	ContentType string
+	Format      PreviewFormat
+}{
+	{"image/png", PreviewFormatPng},
+	{"image/webp", PreviewFormatWebp},
+	{"image/jpeg", PreviewFormatJpeg},
+}
+
+func (f PreviewFormat) ContentType() string {
+	return "image/" + string(f)
+}
+
+func (f PreviewFormat) validate() error {
+	switch f {
+	case PreviewFormatPng, PreviewFormatWebp, PreviewFormatJpeg:
+		return nil
+	}
+	return newFontError(ErrInvalidOptions, nil, "unknown format %q, expected png, webp or jpeg", f)
+}
+
+// quality returns the quality the format is encoded at, only jpeg is lossy so
+// it's 0 for the other formats. Used to normalize the cache key.
+func (o *CreateFontPreviewOptions) quality() int {
+	if o.Format != PreviewFormatJpeg {
+		return 0
+	}
+	return cmp.Or(o.Quality, defaultPreviewQuality)
+}
+
+// encodePreviewImage encodes the image in the format, background is the
+// background of the preview which jpeg images are flattened onto
+func encodePreviewImage(w io.Writer, img image.Image, format PreviewFormat, quality int, background color.NRGBA) error {
+	switch format {
+	case PreviewFormatWebp:
+		return nativewebp.Encode(w, img, nil)
+	case PreviewFormatJpeg:
+		// Jpeg has no alpha channel, flatten the image onto an opaque background first
+		matte := color.NRGBA{R: background.R, G: background.G, B: background.B, A: 255}
+		if background.A == 0 {
+			matte = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		}
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(matte), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		return jpeg.Encode(w, flat, &jpeg.Options{Quality: cmp.Or(quality, defaultPreviewQuality)})
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,94 @@
+package font_service
+
+import (
+	"context"
+
+	"github.com/wandb/parallel"
+
+	"GoogleFontsPluginApi/utils"
+)
+
+const (
+	defaultPreviewConcurrency = 8
+	previewMultiMaxFamilies   = 100
+)
+
+// FontPreviewResult is the preview of one family of a multi preview, Err is
+// set instead of Data when that family failed
+type FontPreviewResult struct {
+	Family FontAndVariant
+	Data   []byte
+	Err    error
+}
+
+// GetPreviewConcurrency reads PREVIEW_CONCURRENCY, how many previews of a
+// multi preview are rendered at the same time
+func GetPreviewConcurrency() int {
+	return max(1, utils.GetEnvInt("PREVIEW_CONCURRENCY", defaultPreviewConcurrency))
+}
+
+// UniqueFamilyAndVariants returns the families of the options without
+// duplicates, in the order they were first given
+func (o *CreateFontPreviewOptions) UniqueFamilyAndVariants() []FontAndVariant {
+	return uniqueFamilyAndVariants(o.FamilyAndVariants())
+}
+
+func uniqueFamilyAndVariants(families []FontAndVariant) []FontAndVariant {
+	seen := map[string]bool{}
+	var unique []FontAndVariant
+	for _, familyData := range families {
+		if seen[familyData.FullName()] {
+			continue
+		}
+		seen[familyData.FullName()] = true
+		unique = append(unique, familyData)
+	}
+	return unique
+}
+
+func (o *CreateFontPreviewOptions) ValidateMulti() error {
+	// Multi previews are json, without a result type the images are base64
+	if o.ResultType == "" {
+		o.ResultType = FontPreviewResultTypeBase64
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if len(o.UniqueFamilyAndVariants()) > previewMultiMaxFamilies {
+		return newFontError(ErrInvalidOptions, nil, "at most %d families can be previewed at once", previewMultiMaxFamilies)
+	}
+	return nil
+}
+
+// mapFamilies calls f for every family, GetPreviewConcurrency at a time since
+// fonts that aren't loaded yet are downloaded, and returns the results in the
+// order of the families
+func mapFamilies[R any](families []FontAndVariant, f func(familyData FontAndVariant) R) []R {
+	results := make([]R, len(families))
+
+	group := parallel.Limited(context.Background(), GetPreviewConcurrency())
+	for i, familyData := range families {
+		group.Go(func(ctx context.Context) {
+			// Every worker only writes its own result, so no locking is needed
+			results[i] = f(familyData)
+		})
+	}
+	group.Wait()
+
+	return results
+}
+
+// RenderFontPreviews renders the preview of every family in the options once,
+// a few at a time. A family that fails doesn't fail the others, its error is
+// returned in its result.
+func RenderFontPreviews(provider IFontProvider, r *CreateFontPreviewOptions) []FontPreviewResult {
+	return mapFamilies(r.UniqueFamilyAndVariants(), func(familyData FontAndVariant) FontPreviewResult {
+		data, err := RenderFontPreview(provider, r, familyData)
+		return FontPreviewResult{
+			Family: familyData,
+			Data:   data,
+			Err:    err,
+		}
+	})
+}
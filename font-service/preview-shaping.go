@@ -0,0 +1,262 @@
+package font_service
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/go-text/typesetting/di"
+	gotext "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+	xlanguage "golang.org/x/text/language"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// previewFont is the font a preview is rendered with, at the axis values of
+// the preview for variable fonts. go-text faces aren't safe for concurrent
+// use, so every render gets its own.
+type previewFont struct {
+	face *gotext.Face
+}
+
+func newPreviewFont(ft *gotext.Font, values []previewAxisValue) *previewFont {
+	face := gotext.NewFace(ft)
+
+	if len(values) > 0 {
+		variations := make([]gotext.Variation, len(values))
+		for i, v := range values {
+			variations[i] = gotext.Variation{Tag: ot.MustNewTag(v.tag), Value: float32(v.value)}
+		}
+		face.SetVariations(variations)
+	}
+
+	return &previewFont{face: face}
+}
+
+// ResolveFace implements shaping.Fontmap, previews use a single font for all
+// of the text
+func (f *previewFont) ResolveFace(rune) *gotext.Face { return f.face }
+
+// outline returns the glyph's segments in font units, y pointing up
+func (f *previewFont) outline(gid gotext.GID) []gotext.Segment {
+	switch data := f.face.GlyphData(gid).(type) {
+	case gotext.GlyphOutline:
+		return data.Segments
+	case gotext.GlyphSVG:
+		return data.Outline.Segments
+	case gotext.GlyphBitmap:
+		if data.Outline != nil {
+			return data.Outline.Segments
+		}
+	}
+	return nil
+}
+
+// unitScale is the size of a font unit in pixels at the font size in points
+func (f *previewFont) unitScale(fontSize float64) float64 {
+	return fontSize * previewDPI / 72 / float64(f.face.Upem())
+}
+
+// shapedGlyph is a glyph positioned relative to the origin of its line, in
+// font units with y pointing up
+type shapedGlyph struct {
+	id   gotext.GID
+	x, y float64
+}
+
+// shapedLine is a line of text shaped in font units, its glyphs are in visual
+// order so right to left text is already reversed
+type shapedLine struct {
+	text   string
+	glyphs []shapedGlyph
+	width  float64
+}
+
+// previewText is the text of a preview and the language it's shaped in, an
+// empty language is detected from the script of the text
+type previewText struct {
+	text     string
+	language language.Language
+}
+
+// shapeLines splits the text into paragraphs on newlines, shapes them and
+// wraps them at maxWidth font units, 0 keeps every paragraph on a single
+// line. Paragraphs are split into runs of the same direction and script so
+// bidirectional text and scripts like Arabic and Devanagari come out right,
+// without a language the language of every run is the most likely language
+// of its script.
+func (f *previewFont) shapeLines(text previewText, maxWidth int) []shapedLine {
+	if maxWidth <= 0 {
+		maxWidth = math.MaxInt32
+	}
+
+	var (
+		segmenter shaping.Segmenter
+		shaper    shaping.HarfbuzzShaper
+		wrapper   shaping.LineWrapper
+		lines     []shapedLine
+	)
+
+	for _, paragraph := range strings.Split(text.text, "\n") {
+		runes := []rune(paragraph)
+		if len(runes) == 0 {
+			lines = append(lines, shapedLine{})
+			continue
+		}
+
+		direction := paragraphDirection(runes)
+
+		// Shaping at one pixel per font unit gives the glyphs in font units, so
+		// the lines can be scaled to any font size without shaping them again
+		inputs := segmenter.Split(shaping.Input{
+			Text:      runes,
+			RunStart:  0,
+			RunEnd:    len(runes),
+			Direction: direction,
+			Face:      f.face,
+			Size:      fixed.I(int(f.face.Upem())),
+		}, f)
+
+		runs := make([]shaping.Output, len(inputs))
+		for i, input := range inputs {
+			input.Language = cmp.Or(text.language, scriptLanguage(input.Script))
+			runs[i] = shaper.Shape(input)
+		}
+
+		wrapped, _ := wrapper.WrapParagraph(shaping.WrapConfig{Direction: direction}, maxWidth, runes, shaping.NewSliceIterator(runs))
+		for _, line := range wrapped {
+			lines = append(lines, newShapedLine(runes, direction, line))
+		}
+	}
+
+	return lines
+}
+
+// newShapedLine positions the glyphs of the wrapped line's runs, the runs are
+// placed in their visual order
+func newShapedLine(paragraph []rune, direction di.Direction, line shaping.Line) shapedLine {
+	if len(line) == 0 {
+		return shapedLine{}
+	}
+
+	// Runs are in logical order, so the first and last runs hold the line's text
+	first, last := line[0], line[len(line)-1]
+	shaped := shapedLine{text: string(paragraph[first.Runes.Offset : last.Runes.Offset+last.Runes.Count])}
+
+	x := 0.0
+	for _, run := range visualRunOrder(paragraph, direction, line) {
+		for _, g := range run.Glyphs {
+			shaped.glyphs = append(shaped.glyphs, shapedGlyph{
+				id: g.GlyphID,
+				x:  x + fixedToFloat(g.XOffset),
+				y:  fixedToFloat(g.YOffset),
+			})
+			x += fixedToFloat(g.XAdvance)
+		}
+	}
+	shaped.width = x
+
+	return shaped
+}
+
+// visualRunOrder reorders the runs of a line from logical to visual order
+// with the bidi algorithm's rule L2. The wrapper's own ordering only looks at
+// the direction of the runs, which puts numbers after right to left text on
+// the wrong side of it, "مرحبا 123" in a left to right paragraph.
+func visualRunOrder(paragraph []rune, direction di.Direction, line shaping.Line) shaping.Line {
+	rtlParagraph := direction.Progression() == di.TowardTopLeft
+
+	levels := make([]int, len(line))
+	for i, run := range line {
+		switch {
+		case run.Direction.Progression() == di.TowardTopLeft:
+			levels[i] = 1
+		case rtlParagraph:
+			levels[i] = 2
+		case !hasStrongLTR(paragraph[run.Runes.Offset:run.Runes.Offset+run.Runes.Count]) && precededByRTL(paragraph[:run.Runes.Offset]):
+			// Numbers following right to left text belong to it
+			levels[i] = 2
+		}
+	}
+
+	runs := slices.Clone(line)
+
+	// From the highest level to the lowest odd level, reverse every sequence
+	// of runs at that level or higher
+	for level := slices.Max(levels); level >= 1; level-- {
+		for start := 0; start < len(runs); {
+			if levels[start] < level {
+				start++
+				continue
+			}
+
+			end := start
+			for end < len(runs) && levels[end] >= level {
+				end++
+			}
+			slices.Reverse(runs[start:end])
+			slices.Reverse(levels[start:end])
+			start = end
+		}
+	}
+
+	return runs
+}
+
+func hasStrongLTR(runes []rune) bool {
+	return slices.ContainsFunc(runes, func(r rune) bool {
+		props, _ := bidi.LookupRune(r)
+		return props.Class() == bidi.L
+	})
+}
+
+// precededByRTL reports whether the last strongly directional rune is right to left
+func precededByRTL(runes []rune) bool {
+	for i := len(runes) - 1; i >= 0; i-- {
+		props, _ := bidi.LookupRune(runes[i])
+		switch props.Class() {
+		case bidi.L:
+			return false
+		case bidi.R, bidi.AL:
+			return true
+		}
+	}
+	return false
+}
+
+// paragraphDirection is the direction of the first strongly directional rune,
+// as the unicode bidi algorithm picks it, left to right if there's none
+func paragraphDirection(runes []rune) di.Direction {
+	for _, r := range runes {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.L:
+			return di.DirectionLTR
+		case bidi.R, bidi.AL:
+			return di.DirectionRTL
+		}
+	}
+	return di.DirectionLTR
+}
+
+// scriptLanguage returns the most likely language of the script, e.g. ar for
+// Arabic and hi for Devanagari, which lets the shaper use the font's
+// language specific forms
+func scriptLanguage(script language.Script) language.Language {
+	tag, err := xlanguage.Parse("und-" + script.String())
+	if err != nil {
+		return language.NewLanguage("und")
+	}
+
+	base, _ := tag.Base()
+	return language.NewLanguage(base.String())
+}
+
+// fixedToFloat converts the 26.6 fixed point positions the shaper returns to pixels
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
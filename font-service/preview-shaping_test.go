@@ -0,0 +1,55 @@
+package font_service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/shaping"
+)
+
+func TestVisualRunOrder(t *testing.T) {
+	type run struct {
+		offset, count int
+		direction     di.Direction
+	}
+	ltr := func(offset, count int) run { return run{offset, count, di.DirectionLTR} }
+	rtl := func(offset, count int) run { return run{offset, count, di.DirectionRTL} }
+
+	tests := []struct {
+		name      string
+		paragraph string
+		direction di.Direction
+		runs      []run
+		// Offsets of the runs in visual order
+		want []int
+	}{
+		{name: "left to right", paragraph: "hello world", direction: di.DirectionLTR, runs: []run{ltr(0, 6), ltr(6, 5)}, want: []int{0, 6}},
+		{name: "single right to left run", paragraph: "abc אבג", direction: di.DirectionLTR, runs: []run{ltr(0, 4), rtl(4, 3)}, want: []int{0, 4}},
+		{name: "right to left runs are reversed", paragraph: "abc אבג דהו", direction: di.DirectionLTR, runs: []run{ltr(0, 4), rtl(4, 4), rtl(8, 3)}, want: []int{0, 8, 4}},
+		// Numbers following right to left text belong to it, so they end up on its left
+		{name: "numbers after right to left text", paragraph: "مرحبا 123", direction: di.DirectionLTR, runs: []run{rtl(0, 6), ltr(6, 3)}, want: []int{6, 0}},
+		{name: "numbers after left to right text", paragraph: "abc 123", direction: di.DirectionLTR, runs: []run{ltr(0, 4), ltr(4, 3)}, want: []int{0, 4}},
+		{name: "numbers after left to right text that follows rtl", paragraph: "אבג abc 123", direction: di.DirectionLTR, runs: []run{rtl(0, 4), ltr(4, 4), ltr(8, 3)}, want: []int{0, 4, 8}},
+		{name: "right to left paragraph", paragraph: "אבג abc", direction: di.DirectionRTL, runs: []run{rtl(0, 4), ltr(4, 3)}, want: []int{4, 0}},
+		{name: "left to right runs in a right to left paragraph keep their order", paragraph: "אבג abc def", direction: di.DirectionRTL, runs: []run{rtl(0, 4), ltr(4, 4), ltr(8, 3)}, want: []int{4, 8, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := make(shaping.Line, len(tt.runs))
+			for i, r := range tt.runs {
+				line[i] = shaping.Output{Direction: r.direction, Runes: shaping.Range{Offset: r.offset, Count: r.count}}
+			}
+
+			var got []int
+			for _, output := range visualRunOrder([]rune(tt.paragraph), tt.direction, line) {
+				got = append(got, output.Runes.Offset)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("visualRunOrder(%q) = %v, want %v", tt.paragraph, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package font_service
+
+import (
+	"bytes"
+	"cmp"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"GoogleFontsPluginApi/utils"
+)
+
+const (
+	// The largest width or height a webp image can have
+	previewSpriteMaxDimension     = 16383
+	defaultPreviewSpriteMaxPixels = 16 * 1024 * 1024
+)
+
+// FontPreviewSpriteEntry is where a family's preview is in the sprite, in
+// pixels of the sprite image. Error is set instead when the family failed.
+type FontPreviewSpriteEntry struct {
+	X      int   `json:"x"`
+	Y      int   `json:"y"`
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	Error  error `json:"-"`
+}
+
+// FontPreviewSprite is a single image holding the previews of many families,
+// laid out in a grid of equally sized cells
+type FontPreviewSprite struct {
+	Image  []byte
+	Format PreviewFormat
+	Width  int
+	Height int
+	Scale  float64
+	// Family name -> the family's cell
+	Entries map[string]FontPreviewSpriteEntry
+}
+
+// GetPreviewSpriteMaxPixels reads PREVIEW_SPRITE_MAX_PIXELS, the largest
+// width * height a sprite can have
+func GetPreviewSpriteMaxPixels() int {
+	return utils.GetEnvInt("PREVIEW_SPRITE_MAX_PIXELS", defaultPreviewSpriteMaxPixels)
+}
+
+// spriteGrid lays out count cells in a grid that is about as wide as it is tall
+func spriteGrid(count, cellWidth, cellHeight int) (columns, rows int) {
+	if count == 0 {
+		return 0, 0
+	}
+
+	columns = int(math.Ceil(math.Sqrt(float64(count*cellHeight) / float64(cellWidth))))
+	columns = max(1, min(count, columns, previewSpriteMaxDimension/cellWidth))
+	rows = (count + columns - 1) / columns
+
+	return columns, rows
+}
+
+func (o *CreateFontPreviewOptions) spriteCellSize() (width, height int, scale float64, err error) {
+	size, err := o.size()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	pixels := size.pixels()
+	return int(pixels.width), int(pixels.height), size.scale, nil
+}
+
+// ValidateSprite checks that the sprite of every family fits within the limits
+func (o *CreateFontPreviewOptions) ValidateSprite() error {
+	if err := o.ValidateMulti(); err != nil {
+		return err
+	}
+
+	cellWidth, cellHeight, _, err := o.spriteCellSize()
+	if err != nil {
+		return err
+	}
+
+	columns, rows := spriteGrid(len(o.UniqueFamilyAndVariants()), cellWidth, cellHeight)
+	width, height := columns*cellWidth, rows*cellHeight
+	if width > previewSpriteMaxDimension || height > previewSpriteMaxDimension || width*height > GetPreviewSpriteMaxPixels() {
+		return newFontError(ErrInvalidOptions, nil, "a %dx%d sprite is too large, request fewer families or smaller previews", width, height)
+	}
+
+	return nil
+}
+
+// RenderFontPreviewSprite renders every family of the options into a single
+// image, a few at a time. Families that fail get no cell, their entry has
+// the error instead.
+func RenderFontPreviewSprite(provider IFontProvider, r *CreateFontPreviewOptions) (*FontPreviewSprite, error) {
+	cellWidth, cellHeight, scale, err := r.spriteCellSize()
+	if err != nil {
+		return nil, err
+	}
+	style, err := r.style()
+	if err != nil {
+		return nil, err
+	}
+
+	families := r.UniqueFamilyAndVariants()
+	cells := mapFamilies(families, func(familyData FontAndVariant) spriteCell {
+		img, err := renderSpriteCell(provider, r, familyData)
+		return spriteCell{img, err}
+	})
+
+	rendered := 0
+	for _, cell := range cells {
+		if cell.err == nil {
+			rendered++
+		}
+	}
+
+	columns, rows := spriteGrid(rendered, cellWidth, cellHeight)
+	sprite := &FontPreviewSprite{
+		Format:  cmp.Or(r.Format, PreviewFormatPng),
+		Width:   max(1, columns*cellWidth),
+		Height:  max(1, rows*cellHeight),
+		Scale:   scale,
+		Entries: make(map[string]FontPreviewSpriteEntry, len(families)),
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, sprite.Width, sprite.Height))
+	placed := 0
+	for i, familyData := range families {
+		if cells[i].err != nil {
+			sprite.Entries[familyData.FullName()] = FontPreviewSpriteEntry{Error: cells[i].err}
+			continue
+		}
+
+		x, y := (placed%columns)*cellWidth, (placed/columns)*cellHeight
+		draw.Draw(canvas, image.Rect(x, y, x+cellWidth, y+cellHeight), cells[i].img, image.Point{}, draw.Src)
+		sprite.Entries[familyData.FullName()] = FontPreviewSpriteEntry{X: x, Y: y, Width: cellWidth, Height: cellHeight}
+		placed++
+	}
+
+	var buf bytes.Buffer
+	if err := encodePreviewImage(&buf, canvas, sprite.Format, r.quality(), style.background); err != nil {
+		return nil, err
+	}
+	sprite.Image = buf.Bytes()
+
+	return sprite, nil
+}
+
+// spriteCell is the rendered preview of one family of a sprite, err is set
+// instead of img when the family failed
+type spriteCell struct {
+	img image.Image
+	err error
+}
+
+// renderSpriteCell renders the family's preview through the preview cache, so
+// families previewed before don't have to be rendered again. Cells are png
+// so they're lossless whatever format the sprite is encoded in.
+func renderSpriteCell(provider IFontProvider, r *CreateFontPreviewOptions, familyData FontAndVariant) (image.Image, error) {
+	cellOptions := *r
+	cellOptions.ResultType = FontPreviewResultTypeRaw
+	cellOptions.Format = PreviewFormatPng
+
+	prepared, err := PrepareFontPreview(provider, &cellOptions, familyData)
+	if err != nil {
+		return nil, err
+	}
+
+	preview, err := prepared.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(preview.Data))
+}
@@ -0,0 +1,80 @@
+package font_service
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSpriteGrid(t *testing.T) {
+	tests := []struct {
+		name                  string
+		count                 int
+		cellWidth, cellHeight int
+		wantColumns, wantRows int
+	}{
+		{name: "no cells", count: 0, cellWidth: 400, cellHeight: 200, wantColumns: 0, wantRows: 0},
+		{name: "single cell", count: 1, cellWidth: 400, cellHeight: 200, wantColumns: 1, wantRows: 1},
+		{name: "square cells", count: 3, cellWidth: 100, cellHeight: 100, wantColumns: 2, wantRows: 2},
+		{name: "wide cells stack up", count: 8, cellWidth: 400, cellHeight: 200, wantColumns: 2, wantRows: 4},
+		{name: "last row is partly filled", count: 9, cellWidth: 400, cellHeight: 200, wantColumns: 3, wantRows: 3},
+		{name: "banners", count: 32, cellWidth: 800, cellHeight: 100, wantColumns: 2, wantRows: 16},
+		// A row can't be wider than the largest image we can encode
+		{name: "columns fit the max dimension", count: 100, cellWidth: 5000, cellHeight: 5000, wantColumns: 3, wantRows: 34},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows := spriteGrid(tt.count, tt.cellWidth, tt.cellHeight)
+			if columns != tt.wantColumns || rows != tt.wantRows {
+				t.Errorf("spriteGrid(%d, %d, %d) = %d, %d, want %d, %d",
+					tt.count, tt.cellWidth, tt.cellHeight, columns, rows, tt.wantColumns, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestValidateSpriteSize(t *testing.T) {
+	families := func(count int) []string {
+		names := make([]string, count)
+		for i := range names {
+			names[i] = "Family " + strconv.Itoa(i)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name string
+		opts CreateFontPreviewOptions
+		// PREVIEW_SPRITE_MAX_PIXELS, the default when 0
+		maxPixels int
+		wantErr   bool
+	}{
+		{name: "fits", opts: CreateFontPreviewOptions{Families: families(10)}},
+		{name: "many banners", opts: CreateFontPreviewOptions{Families: families(100), Small: "true"}},
+		{name: "taller than the max dimension", opts: CreateFontPreviewOptions{Families: families(100), Width: 2048, Height: 2048}, wantErr: true},
+		{name: "over the pixel limit", opts: CreateFontPreviewOptions{Families: families(100), Scale: 4}, wantErr: true},
+		{name: "within a lower limit", opts: CreateFontPreviewOptions{Families: families(12)}, maxPixels: 1_000_000},
+		{name: "over a lower limit", opts: CreateFontPreviewOptions{Families: families(13)}, maxPixels: 1_000_000, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxPixels != 0 {
+				t.Setenv("PREVIEW_SPRITE_MAX_PIXELS", strconv.Itoa(tt.maxPixels))
+			}
+
+			tt.opts.ResultType = FontPreviewResultTypeSprite
+			err := tt.opts.ValidateSprite()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOptions) {
+					t.Errorf("ValidateSprite() error = %v, want an invalid options error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
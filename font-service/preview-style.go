@@ -0,0 +1,180 @@
+package font_service
+
+import (
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+type PreviewAlign string
+
+const (
+	PreviewAlignLeft   PreviewAlign = "left"
+	PreviewAlignCenter PreviewAlign = "center"
+	PreviewAlignRight  PreviewAlign = "right"
+)
+
+// PreviewFit decides what happens to text that doesn't fit on a single line
+type PreviewFit string
+
+const (
+	// Wrap on words, lines that still don't fit overflow the canvas
+	PreviewFitWrap PreviewFit = "wrap"
+	// Keep the lines as they are and use the largest font size at which they fit
+	PreviewFitShrink PreviewFit = "shrink"
+	// Keep the lines as they are and cut off anything outside the padding
+	PreviewFitClip PreviewFit = "clip"
+)
+
+const (
+	defaultPreviewColor      = "#ffffff"
+	defaultPreviewBackground = "transparent"
+	defaultPreviewPadding    = 10
+)
+
+// parsePreviewColor accepts hex colors (#rgb, #rgba, #rrggbb, #rrggbbaa, the #
+// is optional so it doesn't have to be escaped in urls), rgb(r, g, b),
+// rgba(r, g, b, a) with an alpha between 0 and 1, and "transparent"
+func parsePreviewColor(value string) (color.NRGBA, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if value == "transparent" {
+		return color.NRGBA{}, nil
+	}
+
+	if args, found := strings.CutPrefix(value, "rgba("); found {
+		return parseRGBFunction(value, args, true)
+	}
+	if args, found := strings.CutPrefix(value, "rgb("); found {
+		return parseRGBFunction(value, args, false)
+	}
+
+	return parseHexColor(value)
+}
+
+func parseHexColor(value string) (color.NRGBA, error) {
+	digits := strings.TrimPrefix(value, "#")
+
+	// Expand the short forms, "f0a" -> "ff00aa"
+	if len(digits) == 3 || len(digits) == 4 {
+		var expanded strings.Builder
+		for _, d := range digits {
+			expanded.WriteRune(d)
+			expanded.WriteRune(d)
+		}
+		digits = expanded.String()
+	}
+	if len(digits) == 6 {
+		digits += "ff"
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil || len(b) != 4 {
+		return color.NRGBA{}, fmt.Errorf("expected a hex, rgb() or rgba() color, got %q", value)
+	}
+
+	return color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+}
+
+func parseRGBFunction(value, args string, hasAlpha bool) (color.NRGBA, error) {
+	args, found := strings.CutSuffix(args, ")")
+	if !found {
+		return color.NRGBA{}, fmt.Errorf("expected a hex, rgb() or rgba() color, got %q", value)
+	}
+
+	parts := strings.Split(args, ",")
+	if (hasAlpha && len(parts) != 4) || (!hasAlpha && len(parts) != 3) {
+		return color.NRGBA{}, fmt.Errorf("expected a hex, rgb() or rgba() color, got %q", value)
+	}
+
+	var channels [3]uint8
+	for i := range channels {
+		c, err := strconv.ParseUint(strings.TrimSpace(parts[i]), 10, 8)
+		if err != nil {
+			return color.NRGBA{}, fmt.Errorf("channels must be between 0 and 255, got %q", value)
+		}
+		channels[i] = uint8(c)
+	}
+
+	alpha := uint8(255)
+	if hasAlpha {
+		a, err := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+		if err != nil || a < 0 || a > 1 {
+			return color.NRGBA{}, fmt.Errorf("alpha must be between 0 and 1, got %q", value)
+		}
+		alpha = uint8(a*255 + 0.5)
+	}
+
+	return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: alpha}, nil
+}
+
+// svgColor returns the color as an svg fill and its opacity
+func svgColor(c color.NRGBA) (string, string) {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B), svgNumber(float64(c.A) / 255)
+}
+
+// previewStyle is the parsed and validated styling of a preview
+type previewStyle struct {
+	color      color.NRGBA
+	background color.NRGBA
+	padding    float64
+	align      PreviewAlign
+	fit        PreviewFit
+}
+
+// templateValue treats placeholders of the preview template a client didn't
+// fill in, like "{Color}", as if the option wasn't given
+func templateValue[T ~string](value T) T {
+	if strings.HasPrefix(string(value), "{") && strings.HasSuffix(string(value), "}") {
+		return ""
+	}
+	return value
+}
+
+func (o *CreateFontPreviewOptions) style() (previewStyle, error) {
+	style := previewStyle{
+		padding: defaultPreviewPadding,
+		align:   cmp.Or(templateValue(o.Align), PreviewAlignCenter),
+		fit:     cmp.Or(o.Fit, PreviewFitWrap),
+	}
+
+	var err error
+	if padding := templateValue(o.Padding); padding != "" {
+		if style.padding, err = strconv.ParseFloat(padding, 64); err != nil {
+			return style, newFontError(ErrInvalidOptions, err, "invalid padding %q", o.Padding)
+		}
+	}
+	if style.color, err = parsePreviewColor(cmp.Or(templateValue(o.Color), defaultPreviewColor)); err != nil {
+		return style, newFontError(ErrInvalidOptions, nil, "invalid color: %v", err)
+	}
+	if style.background, err = parsePreviewColor(cmp.Or(templateValue(o.Background), defaultPreviewBackground)); err != nil {
+		return style, newFontError(ErrInvalidOptions, nil, "invalid background: %v", err)
+	}
+
+	switch style.align {
+	case PreviewAlignLeft, PreviewAlignCenter, PreviewAlignRight:
+	default:
+		return style, newFontError(ErrInvalidOptions, nil, "unknown align %q, expected left, center or right", o.Align)
+	}
+
+	switch style.fit {
+	case PreviewFitWrap, PreviewFitShrink, PreviewFitClip:
+	default:
+		return style, newFontError(ErrInvalidOptions, nil, "unknown fit %q, expected shrink, wrap or clip", o.Fit)
+	}
+
+	size, err := o.size()
+	if err != nil {
+		return style, err
+	}
+
+	// Written so NaN fails the check too
+	if !(style.padding >= 0 && style.padding*2 < min(size.width, size.height)) {
+		return style, newFontError(ErrInvalidOptions, nil, "padding must be between 0 and %v", min(size.width, size.height)/2)
+	}
+
+	return style, nil
+}
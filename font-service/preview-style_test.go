@@ -0,0 +1,137 @@
+package font_service
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestParsePreviewColor(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{value: "#ffffff", want: color.NRGBA{255, 255, 255, 255}},
+		{value: "ff8000", want: color.NRGBA{255, 128, 0, 255}},
+		{value: "#F80", want: color.NRGBA{255, 136, 0, 255}},
+		{value: "#f808", want: color.NRGBA{255, 136, 0, 136}},
+		{value: "#ff800080", want: color.NRGBA{255, 128, 0, 128}},
+		{value: "  #000000  ", want: color.NRGBA{0, 0, 0, 255}},
+		{value: "transparent", want: color.NRGBA{}},
+		{value: "Transparent", want: color.NRGBA{}},
+		{value: "rgb(10, 20, 30)", want: color.NRGBA{10, 20, 30, 255}},
+		{value: "rgba(10,20,30,0.5)", want: color.NRGBA{10, 20, 30, 128}},
+		{value: "rgba(10, 20, 30, 0)", want: color.NRGBA{10, 20, 30, 0}},
+		{value: "", wantErr: true},
+		{value: "#ff", wantErr: true},
+		{value: "#ggg", wantErr: true},
+		{value: "red", wantErr: true},
+		{value: "rgb(10, 20)", wantErr: true},
+		{value: "rgb(10, 20, 30", wantErr: true},
+		{value: "rgb(10, 20, 256)", wantErr: true},
+		{value: "rgb(-1, 20, 30)", wantErr: true},
+		{value: "rgba(10, 20, 30)", wantErr: true},
+		{value: "rgba(10, 20, 30, 1.5)", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parsePreviewColor(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsePreviewColor(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePreviewColor(%q) unexpected error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("parsePreviewColor(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewStyleTemplateDefaults(t *testing.T) {
+	defaults := &CreateFontPreviewOptions{}
+	defaultStyle, err := defaults.style()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defaultSize, err := defaults.size()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts CreateFontPreviewOptions
+	}{
+		{name: "unfilled placeholders", opts: CreateFontPreviewOptions{Color: "{Color}", Background: "{Background}", Padding: "{Padding}", Align: "{Align}"}},
+		{name: "empty values", opts: CreateFontPreviewOptions{Color: "", Background: "", Padding: "", Align: ""}},
+		{name: "explicit defaults", opts: CreateFontPreviewOptions{Color: defaultPreviewColor, Background: defaultPreviewBackground, Padding: "10", Align: PreviewAlignCenter}},
+		{name: "unfilled text", opts: CreateFontPreviewOptions{Text: "{Text}"}},
+		{name: "unfilled small", opts: CreateFontPreviewOptions{Small: "{IsSmall}"}},
+		{name: "explicit large", opts: CreateFontPreviewOptions{Small: "false"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			style, err := tt.opts.style()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if style != defaultStyle {
+				t.Errorf("style() = %+v, want the defaults %+v", style, defaultStyle)
+			}
+
+			size, err := tt.opts.size()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size != defaultSize {
+				t.Errorf("size() = %+v, want the default %+v", size, defaultSize)
+			}
+
+			// Without a text previews show the family's default text
+			if text := tt.opts.text(); text != "" {
+				t.Errorf("text() = %q, want no text", text)
+			}
+		})
+	}
+}
+
+func TestPreviewSmallOption(t *testing.T) {
+	tests := []struct {
+		small   string
+		want    bool
+		wantErr bool
+	}{
+		{small: "", want: false},
+		{small: "{IsSmall}", want: false},
+		{small: "true", want: true},
+		{small: "1", want: true},
+		{small: "false", want: false},
+		{small: "maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.small, func(t *testing.T) {
+			got, err := (&CreateFontPreviewOptions{Small: tt.small}).small()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOptions) {
+					t.Errorf("small() error = %v, want an invalid options error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("small() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package font_service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// encodeSVG writes the preview as an svg document, every line becomes a path
+// built from the same glyph outlines the raster output draws. A css fill on
+// the paths overrides the text color.
+func (l *previewLayout) encodeSVG(w io.Writer) error {
+	var text strings.Builder
+	paths := make([]string, 0, len(l.lines))
+
+	for _, line := range l.lines {
+		var d svgPath
+		l.addLineOutlines(&d, line, 0, 0)
+
+		if d.Len() > 0 {
+			paths = append(paths, d.String())
+		}
+
+		if text.Len() > 0 {
+			text.WriteByte(' ')
+		}
+		text.WriteString(line.text)
+	}
+
+	var label strings.Builder
+	if err := xml.EscapeText(&label, []byte(text.String())); err != nil {
+		return err
+	}
+
+	width, height := svgNumber(l.size.width), svgNumber(l.size.height)
+	if _, err := fmt.Fprintf(w,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" role="img" aria-label="%s">`,
+		width, height, width, height, label.String(),
+	); err != nil {
+		return err
+	}
+
+	if l.style.background.A > 0 {
+		fill, opacity := svgColor(l.style.background)
+		if _, err := fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="%s" fill-opacity="%s"/>`, fill, opacity); err != nil {
+			return err
+		}
+	}
+
+	// Clip mode cuts the text off at the padding
+	if l.style.fit == PreviewFitClip {
+		clipX, clipY, clipWidth, clipHeight := l.contentBox()
+		if _, err := fmt.Fprintf(w,
+			`<clipPath id="preview-clip"><rect x="%s" y="%s" width="%s" height="%s"/></clipPath><g clip-path="url(#preview-clip)">`,
+			svgNumber(clipX), svgNumber(clipY), svgNumber(clipWidth), svgNumber(clipHeight),
+		); err != nil {
+			return err
+		}
+	}
+
+	fill, opacity := svgColor(l.style.color)
+	for _, d := range paths {
+		if _, err := fmt.Fprintf(w, `<path fill="%s" fill-opacity="%s" d="%s"/>`, fill, opacity, d); err != nil {
+			return err
+		}
+	}
+
+	if l.style.fit == PreviewFitClip {
+		if _, err := io.WriteString(w, "</g>"); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "</svg>")
+	return err
+}
+
+// svgPath writes outlines as the commands of an svg path
+type svgPath struct {
+	strings.Builder
+}
+
+func (p *svgPath) MoveTo(x, y float32) { p.WriteString("M" + svgPoint(x, y)) }
+func (p *svgPath) LineTo(x, y float32) { p.WriteString("L" + svgPoint(x, y)) }
+func (p *svgPath) ClosePath()          { p.WriteString("Z") }
+
+func (p *svgPath) QuadTo(bx, by, x, y float32) {
+	p.WriteString("Q" + svgPoint(bx, by) + " " + svgPoint(x, y))
+}
+
+func (p *svgPath) CubeTo(bx, by, cx, cy, x, y float32) {
+	p.WriteString("C" + svgPoint(bx, by) + " " + svgPoint(cx, cy) + " " + svgPoint(x, y))
+}
+
+func svgPoint(x, y float32) string {
+	return svgNumber(float64(x)) + " " + svgNumber(float64(y))
+}
+
+// svgNumber formats coordinates with at most two decimals to keep paths small
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
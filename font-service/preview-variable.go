@@ -0,0 +1,90 @@
+package font_service
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// previewAxisValue is the value a variable font axis is rendered at
+type previewAxisValue struct {
+	tag   string
+	value float64
+}
+
+// axisValues parses the axes option, a list of tag:value pairs like wght:650,
+// sorted by tag so the same axes in a different order are the same preview
+func (o *CreateFontPreviewOptions) axisValues() ([]previewAxisValue, error) {
+	values := make([]previewAxisValue, 0, len(o.Axes))
+	for _, axis := range o.Axes {
+		tag, valueStr, found := strings.Cut(strings.TrimSpace(axis), ":")
+		if !found || len(tag) != 4 {
+			return nil, newFontError(ErrInvalidOptions, nil, "invalid axis %q, expected a 4 character tag and a value like wght:650", axis)
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, newFontError(ErrInvalidOptions, nil, "invalid value for axis %s, expected a number, got %q", tag, valueStr)
+		}
+
+		if slices.ContainsFunc(values, func(v previewAxisValue) bool { return v.tag == tag }) {
+			return nil, newFontError(ErrInvalidOptions, nil, "axis %s is given more than once", tag)
+		}
+
+		values = append(values, previewAxisValue{tag: tag, value: value})
+	}
+
+	slices.SortFunc(values, func(a, b previewAxisValue) int { return strings.Compare(a.tag, b.tag) })
+
+	return values, nil
+}
+
+// variations checks the requested axis values against the family's axes and
+// returns what the variable font should be rendered at, nil when no axes are
+// requested. The variant's weight and style fill in wght and ital when the
+// family has those axes and they weren't requested, so wdth:75 on a 700
+// variant stays bold.
+func (o *CreateFontPreviewOptions) variations(data *FontFamilyAndVariantData) ([]previewAxisValue, error) {
+	values, err := o.axisValues()
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	family := data.Family
+	if len(family.Axes) == 0 || data.Variant.VariableDownloadURL == "" {
+		return nil, newFontError(ErrInvalidOptions, nil, "%s is not a variable font", family.Name)
+	}
+
+	for _, v := range values {
+		axis, found := family.Axis(v.tag)
+		if !found {
+			tags := make([]string, len(family.Axes))
+			for i, a := range family.Axes {
+				tags[i] = a.Tag
+			}
+			return nil, newFontError(ErrInvalidOptions, nil, "%s has no %s axis, expected one of %s", family.Name, v.tag, strings.Join(tags, ", "))
+		}
+		if v.value < axis.Min || v.value > axis.Max {
+			return nil, newFontError(ErrInvalidOptions, nil, "axis %s of %s must be between %v and %v", v.tag, family.Name, axis.Min, axis.Max)
+		}
+	}
+
+	weight, italic := variantWeightAndStyle(data.Variant.Name)
+	variantValues := []previewAxisValue{{"wght", float64(weight)}}
+	if italic {
+		variantValues = append(variantValues, previewAxisValue{"ital", 1})
+	}
+
+	for _, v := range variantValues {
+		axis, found := family.Axis(v.tag)
+		if !found || slices.ContainsFunc(values, func(value previewAxisValue) bool { return value.tag == v.tag }) {
+			continue
+		}
+		values = append(values, previewAxisValue{v.tag, min(max(v.value, axis.Min), axis.Max)})
+	}
+
+	slices.SortFunc(values, func(a, b previewAxisValue) int { return strings.Compare(a.tag, b.tag) })
+
+	return values, nil
+}
@@ -0,0 +1,99 @@
+package font_service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAxisValues(t *testing.T) {
+	tests := []struct {
+		axes    []string
+		want    []previewAxisValue
+		wantErr bool
+	}{
+		{axes: nil, want: nil},
+		{axes: []string{"wght:650"}, want: []previewAxisValue{{"wght", 650}}},
+		{axes: []string{" wdth: 75.5 "}, want: []previewAxisValue{{"wdth", 75.5}}},
+		// Sorted by tag so the order they're given in doesn't matter
+		{axes: []string{"wght:700", "ital:1", "wdth:75"}, want: []previewAxisValue{{"ital", 1}, {"wdth", 75}, {"wght", 700}}},
+		{axes: []string{"wght"}, wantErr: true},
+		{axes: []string{"wg:700"}, wantErr: true},
+		{axes: []string{"weight:700"}, wantErr: true},
+		{axes: []string{"wght:bold"}, wantErr: true},
+		{axes: []string{"wght:NaN"}, wantErr: true},
+		{axes: []string{"wght:Inf"}, wantErr: true},
+		{axes: []string{"wght:300", "wght:700"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.axes, ","), func(t *testing.T) {
+			got, err := (&CreateFontPreviewOptions{Axes: tt.axes}).axisValues()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOptions) {
+					t.Errorf("axisValues() error = %v, want an invalid options error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("axisValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewVariations(t *testing.T) {
+	variant := func(family FontFamilyData, name, variableURL string) *FontFamilyAndVariantData {
+		return &FontFamilyAndVariantData{
+			Family:  family,
+			Variant: FontFamilyVariant{Name: name, VariableDownloadURL: variableURL},
+		}
+	}
+
+	variable := FontFamilyData{Name: "Variable", Axes: []FontAxis{{Tag: "wght", Min: 100, Max: 900}, {Tag: "wdth", Min: 75, Max: 100}}}
+	italic := FontFamilyData{Name: "Italic", Axes: []FontAxis{{Tag: "wght", Min: 100, Max: 900}, {Tag: "ital", Min: 0, Max: 1}}}
+	narrow := FontFamilyData{Name: "Narrow", Axes: []FontAxis{{Tag: "wght", Min: 300, Max: 700}, {Tag: "wdth", Min: 75, Max: 100}}}
+	static := FontFamilyData{Name: "Static"}
+
+	tests := []struct {
+		name    string
+		data    *FontFamilyAndVariantData
+		axes    []string
+		want    []previewAxisValue
+		wantErr bool
+	}{
+		{name: "no axes", data: variant(variable, "700", "file.ttf"), want: nil},
+		{name: "no axes on a static font", data: variant(static, "regular", ""), want: nil},
+		{name: "weight of the variant", data: variant(variable, "700", "file.ttf"), axes: []string{"wdth:75"}, want: []previewAxisValue{{"wdth", 75}, {"wght", 700}}},
+		{name: "weight of regular", data: variant(variable, "regular", "file.ttf"), axes: []string{"wdth:75"}, want: []previewAxisValue{{"wdth", 75}, {"wght", 400}}},
+		{name: "requested weight wins", data: variant(variable, "700", "file.ttf"), axes: []string{"wght:300"}, want: []previewAxisValue{{"wght", 300}}},
+		{name: "italic variant", data: variant(italic, "700italic", "file.ttf"), axes: []string{"wght:500"}, want: []previewAxisValue{{"ital", 1}, {"wght", 500}}},
+		{name: "weight is clamped to the axis", data: variant(narrow, "900", "file.ttf"), axes: []string{"wdth:80"}, want: []previewAxisValue{{"wdth", 80}, {"wght", 700}}},
+		{name: "static font", data: variant(static, "regular", ""), axes: []string{"wght:500"}, wantErr: true},
+		{name: "no variable file", data: variant(variable, "regular", ""), axes: []string{"wght:500"}, wantErr: true},
+		{name: "unknown axis", data: variant(variable, "regular", "file.ttf"), axes: []string{"opsz:12"}, wantErr: true},
+		{name: "out of range", data: variant(variable, "regular", "file.ttf"), axes: []string{"wght:1000"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&CreateFontPreviewOptions{Axes: tt.axes}).variations(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOptions) {
+					t.Errorf("variations() error = %v, want an invalid options error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("variations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,15 @@
 package font_service
 
 import (
-	"io"
-	"net/http"
+	"maps"
+	"os"
 	"path"
+	"slices"
 	"sync"
 	"time"
 
+	gotext "github.com/go-text/typesetting/font"
 	"github.com/gofiber/fiber/v3"
-	"github.com/golang/freetype/truetype"
 
 	"GoogleFontsPluginApi/cache"
 	"GoogleFontsPluginApi/logger"
@@ -20,7 +21,12 @@ type FontProviderService struct {
 	sync.Mutex
 
 	Providers map[string]*FontProvider
-	FontCache *cache.TTLCache[string, *truetype.Font]
+	// Parsed fonts of the variants, and of the variable fonts previews at
+	// custom axis values are rendered with
+	FontCache *cache.TTLCache[string, *gotext.Font]
+
+	fileStoresMu sync.Mutex
+	fileStores   map[string]*FontFileStore
 }
 
 type FontProvider struct {
@@ -29,6 +35,12 @@ type FontProvider struct {
 	EndPoint    string `json:"endpoint"`
 
 	internalProvider IFontProvider
+
+	// Held for the whole of a catalog refresh
+	refreshMu sync.Mutex
+
+	diffMu   sync.Mutex
+	lastDiff *CatalogDiff
 }
 
 func (f *FontProvider) GetPath(subPaths ...string) string { return GetProviderPath(f.Id, subPaths...) }
@@ -48,10 +60,48 @@ func (f *FontProvider) GetFontAndVariant(family, variant string) (*FontFamilyAnd
 }
 
 func (f *FontProvider) GetCategories() []string { return f.internalProvider.GetCategories() }
+func (f *FontProvider) FetchFontData(variant FontFamilyVariant) ([]byte, error) {
+	return f.internalProvider.FetchFontData(variant)
+}
+func (f *FontProvider) DownloadLicense(font FontFamilyData) (string, error) {
+	return f.internalProvider.DownloadLicense(font)
+}
 func (f *FontProvider) InitializeFromCache(data []FontFamilyData) {
 	f.internalProvider.InitializeFromCache(data)
 }
 
+// catalogCategories are the categories of a provider's catalog, they're
+// replaced by catalog refreshes while requests read them
+type catalogCategories struct {
+	mu         sync.Mutex
+	categories []string
+}
+
+func (c *catalogCategories) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.categories == nil {
+		return []string{}
+	}
+	return c.categories
+}
+
+func (c *catalogCategories) set(unique map[string]bool) {
+	categories := slices.Collect(maps.Keys(unique))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.categories = categories
+}
+
+// catalogWatcher is implemented by providers that pick up changes to their
+// catalog on their own, they start watching once they're registered
+type catalogWatcher interface {
+	watchCatalog(provider *FontProvider)
+}
+
 type IFontProvider interface {
 	GetId() string
 	GetDisplayName() string
@@ -61,15 +111,28 @@ type IFontProvider interface {
 	GetFontAndVariant(family, variant string) (*FontFamilyAndVariantData, error)
 	GetCategories() []string
 	InitializeFromCache(data []FontFamilyData)
+	// FetchFontData returns the raw font file for the variant
+	FetchFontData(variant FontFamilyVariant) ([]byte, error)
+	// DownloadLicense returns the license text of the family, or an empty string if it has none
+	DownloadLicense(font FontFamilyData) (string, error)
 }
 
 func init() {
 	FontProviders = &FontProviderService{
-		Providers: map[string]*FontProvider{},
-		FontCache: cache.NewTTL[string, *truetype.Font](time.Hour * 24),
+		Providers:  map[string]*FontProvider{},
+		FontCache:  cache.NewTTL[string, *gotext.Font](time.Hour * 24),
+		fileStores: map[string]*FontFileStore{},
 	}
+}
 
-	FontProviders.AddProvider(NewGoogleFontsProvider())
+// InitProviders registers the configured providers and loads their catalogs.
+// It runs after the config/env has been loaded, rather than in init, so the
+// providers can be configured.
+func InitProviders() {
+	FontProviders.AddProvider(NewGoogleFontsProvider(GoogleFontsConfigFromEnv()))
+	if dir := os.Getenv("LOCAL_FONTS_DIR"); dir != "" {
+		FontProviders.AddProvider(NewLocalFontsProvider(dir))
+	}
 
 	for _, provider := range FontProviders.Providers {
 		if err := loadProviderCacheFromDisk(provider); err != nil {
@@ -80,8 +143,22 @@ func init() {
 		if err := saveProviderCacheToDisk(provider); err != nil {
 			logger.Error("Failed to save cache for provider %s: %v", provider.GetId(), err)
 		}
+
+		if err := provider.loadLastCatalogDiff(); err != nil {
+			logger.Error("Failed to load catalog diff for provider %s: %v", provider.GetId(), err)
+		}
+
+		if err := ensureCatalogSnapshot(provider); err != nil {
+			logger.Error("Failed to save catalog snapshot for provider %s: %v", provider.GetId(), err)
+		}
+
+		if watcher, ok := provider.internalProvider.(catalogWatcher); ok {
+			watcher.watchCatalog(provider)
+		}
 	}
 
+	FontProviders.StartCatalogRefresh(GetCatalogRefreshInterval())
+
 	logger.Info("Font providers initialized")
 }
 
@@ -100,35 +177,18 @@ func GetFontProviderFromCtx(c fiber.Ctx) IFontProvider {
 	return fiber.Locals[IFontProvider](c, "provider")
 }
 
-func GetOrCacheFont(data *FontFamilyAndVariantData) (*truetype.Font, error) {
-	FontProviders.Lock()
-	defer FontProviders.Unlock()
-
-	font, found := FontProviders.FontCache.Get(data.FontCacheKey())
-	if found {
-		return font, nil
-	}
-
-	resp, err := http.Get(data.Variant.DownloadURL)
-	if err != nil {
-		panic(err)
-	}
-	defer resp.Body.Close()
-
-	fontData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
-	}
+// GetFontFileStore returns the on disk store of downloaded font files for the provider
+func (s *FontProviderService) GetFontFileStore(providerId string) *FontFileStore {
+	s.fileStoresMu.Lock()
+	defer s.fileStoresMu.Unlock()
 
-	// Parse the font and create a font face
-	ft, err := truetype.Parse(fontData)
-	if err != nil {
-		return nil, err
+	store, found := s.fileStores[providerId]
+	if !found {
+		store = NewFontFileStore(providerId, GetFontStoreMaxBytes())
+		s.fileStores[providerId] = store
 	}
 
-	FontProviders.FontCache.Set(data.FontCacheKey(), ft)
-
-	return ft, nil
+	return store
 }
 
 func (s *FontProviderService) GetProviderById(id string) IFontProvider {
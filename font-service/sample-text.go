@@ -0,0 +1,92 @@
+package font_service
+
+import (
+	_ "embed"
+	"slices"
+	"sync"
+
+	gotext "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/language"
+	"github.com/goccy/go-json"
+)
+
+// The subset whose sample text is used when a family has none of the subsets
+// of sample-texts.json, e.g. a local font whose subsets couldn't be detected
+const defaultSampleSubset = "latin"
+
+// SampleText is the text previews of a family show when no text is given,
+// picked by the family's primary subset
+type SampleText struct {
+	Subset string `json:"subset"`
+	// BCP 47 language the text is shaped in, empty to detect it from the script
+	Language string `json:"language"`
+	Text     string `json:"text"`
+}
+
+// sample-texts.json lists a sample text per subset in order of priority, a
+// family's primary subset is the first one it supports. CJK subsets come
+// first as CJK fonts like Noto Sans JP cover latin too, then latin, so the
+// other scripts are only primary for fonts without latin. Latin fonts with
+// another script, like Poppins with devanagari, stay latin.
+//
+//go:embed sample-texts.json
+var sampleTextsData []byte
+
+var getSampleTexts = sync.OnceValue(func() []SampleText {
+	var texts []SampleText
+	if err := json.Unmarshal(sampleTextsData, &texts); err != nil {
+		panic(err)
+	}
+	return texts
+})
+
+// GetSampleText returns the sample text of the primary subset of a family
+// with the subsets
+func GetSampleText(subsets []string) SampleText {
+	return sampleTextsFor(subsets)[0]
+}
+
+// sampleTextsFor returns the sample texts of the subsets in order of
+// priority, or the default subset's when there are none
+func sampleTextsFor(subsets []string) []SampleText {
+	var matching []SampleText
+	for _, t := range getSampleTexts() {
+		if slices.Contains(subsets, t.Subset) {
+			matching = append(matching, t)
+		}
+	}
+
+	if len(matching) == 0 {
+		i := slices.IndexFunc(getSampleTexts(), func(t SampleText) bool { return t.Subset == defaultSampleSubset })
+		matching = append(matching, getSampleTexts()[i])
+	}
+
+	return matching
+}
+
+// showsFamilyName reports whether the previews of a family with the subsets
+// show its name by default, which is the case when latin is its primary subset
+func showsFamilyName(subsets []string) bool {
+	return GetSampleText(subsets).Subset == defaultSampleSubset
+}
+
+// defaultPreviewText picks the text of a preview that wasn't given any. Latin
+// families show their name when the font has every glyph of it, otherwise
+// the first sample text of the family's subsets the font fully covers is
+// used, and the primary subset's when it covers none of them.
+func defaultPreviewText(family FontFamilyData, ft *gotext.Font) previewText {
+	if showsFamilyName(family.Subsets) && AnalyzeFontCoverage(ft, family.Name).Percentage == 100 {
+		return previewText{text: family.Name}
+	}
+
+	samples := sampleTextsFor(family.Subsets)
+	sample := samples[0]
+	for _, s := range samples {
+		if AnalyzeFontCoverage(ft, s.Text).Percentage == 100 {
+			sample = s
+			break
+		}
+	}
+
+	return previewText{text: sample.Text, language: language.NewLanguage(sample.Language)}
+}
@@ -0,0 +1,34 @@
+package font_service
+
+import "testing"
+
+func TestGetSampleText(t *testing.T) {
+	tests := []struct {
+		name    string
+		subsets []string
+		want    string
+	}{
+		{name: "no subsets", subsets: nil, want: "latin"},
+		{name: "unknown subsets", subsets: []string{"klingon"}, want: "latin"},
+		{name: "latin", subsets: []string{"latin", "latin-ext"}, want: "latin"},
+		// Latin fonts with another script stay latin
+		{name: "latin before other scripts", subsets: []string{"cyrillic", "devanagari", "latin"}, want: "latin"},
+		// CJK fonts cover latin too
+		{name: "cjk before latin", subsets: []string{"latin", "japanese"}, want: "japanese"},
+		{name: "script without latin", subsets: []string{"arabic", "latin-ext"}, want: "arabic"},
+		{name: "latin-ext alone", subsets: []string{"latin-ext"}, want: "latin-ext"},
+		{name: "order of the subsets doesn't matter", subsets: []string{"greek", "cyrillic"}, want: "cyrillic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetSampleText(tt.subsets)
+			if got.Subset != tt.want {
+				t.Errorf("GetSampleText(%q) = %q, want the %s sample", tt.subsets, got.Subset, tt.want)
+			}
+			if got.Text == "" {
+				t.Errorf("GetSampleText(%q) has no text", tt.subsets)
+			}
+		})
+	}
+}
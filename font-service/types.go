@@ -1,24 +1,64 @@
 package font_service
 
 import (
+	"cmp"
 	"io"
+	"iter"
 	"os"
+	"path"
 	"slices"
 	"strings"
 )
 
+// FontFamilyOrderValues holds the position of the family in each of the
+// orderings captured when the catalog is synced, 0 being the first.
 type FontFamilyOrderValues struct {
 	Popularity int `json:"popularity"`
+	Trending   int `json:"trending"`
+	// 0 is the most recently added family
+	DateAdded int `json:"dateAdded"`
 }
 
 type FontFamilyData struct {
 	Name     string `json:"name"`
 	Category string `json:"category"`
+	Designer string `json:"designer,omitempty"`
 	// Set to true so it can be re-validated when we try to download the license
 	HasLicense bool                `json:"hasLicense"`
 	Variants   []FontFamilyVariant `json:"variants"`
 
 	Order FontFamilyOrderValues `json:"order"`
+
+	// Scripts the family supports, using the google fonts subset names (latin, cyrillic, greek...)
+	Subsets []string `json:"subsets"`
+	// Version and LastModified as reported by the provider, used to detect updated families
+	Version      string `json:"version"`
+	LastModified string `json:"lastModified"`
+	// Variation axes of variable families, empty for static families
+	Axes []FontAxis `json:"axes,omitempty"`
+
+	// How well the family matched the search, only set on search results
+	SearchScore float64 `json:"searchScore,omitempty"`
+}
+
+// isLegacyCacheData reports whether the data was cached before subsets,
+// version and lastModified were stored, in which case it should be re-fetched
+func isLegacyCacheData(data []FontFamilyData) bool {
+	for _, f := range data {
+		if f.Subsets != nil || f.Version != "" || f.LastModified != "" {
+			return false
+		}
+	}
+	return len(data) > 0
+}
+
+func (d FontFamilyData) SupportsSubsets(subsets []string) bool {
+	for _, subset := range subsets {
+		if !slices.Contains(d.Subsets, subset) {
+			return false
+		}
+	}
+	return true
 }
 
 func (d FontFamilyData) GetLicenseContent(provider IFontProvider) (string, error) {
@@ -39,11 +79,37 @@ func (d FontFamilyData) GetLicenseContent(provider IFontProvider) (string, error
 	return string(license), nil
 }
 
+// FontAxis is a variation axis of a variable family, like wght or opsz, with
+// the range of values it supports in the axis' own units
+type FontAxis struct {
+	Tag string  `json:"tag"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func (d FontFamilyData) Axis(tag string) (FontAxis, bool) {
+	for _, axis := range d.Axes {
+		if axis.Tag == tag {
+			return axis, true
+		}
+	}
+	return FontAxis{}, false
+}
+
 type FontFamilyVariant struct {
-	Name        string               `json:"name"`
-	FullName    string               `json:"fullName"`
-	DownloadURL string               `json:"downloadUrl"`
-	Preview     VariantPreviewObject `json:"preview"`
+	Name        string `json:"name"`
+	FullName    string `json:"fullName"`
+	DownloadURL string `json:"downloadUrl"`
+	// The variable font file the variant is an instance of, used to render
+	// previews at custom axis values. Empty for static families.
+	VariableDownloadURL string               `json:"variableDownloadUrl,omitempty"`
+	Preview             VariantPreviewObject `json:"preview"`
+
+	// The file on disk for providers serving local files, clients download
+	// it from DownloadURL instead
+	FilePath string `json:"-"`
+	// The variable font file on disk, see FilePath
+	VariableFilePath string `json:"-"`
 }
 
 type VariantPreviewObject struct {
@@ -61,20 +127,70 @@ func (f *FontFamilyAndVariantData) FontCacheKey() string {
 	return f.Family.Name + ":" + f.Variant.Name
 }
 
+// VariableFont returns the variable font file of the variant as a variant of
+// its own, named after the file so variants sharing a file share its download
+// and parsed font
+func (f *FontFamilyAndVariantData) VariableFont() (*FontFamilyAndVariantData, bool) {
+	fileURL := f.Variant.VariableDownloadURL
+	if fileURL == "" {
+		return nil, false
+	}
+
+	file := cmp.Or(f.Variant.VariableFilePath, fileURL)
+	name := "variable-" + strings.TrimSuffix(path.Base(file), path.Ext(file))
+	return &FontFamilyAndVariantData{
+		Family: f.Family,
+		Variant: FontFamilyVariant{
+			Name:        name,
+			FullName:    f.Family.Name + ":" + name,
+			DownloadURL: fileURL,
+			FilePath:    f.Variant.VariableFilePath,
+		},
+	}, true
+}
+
 type GetFontsFilters struct {
 	Categories []string `json:"categories,omitempty" query:"categories"`
-	Search     *string  `json:"search,omitempty" query:"search,default:nil"`
+	// Fonts have to support every one of these subsets
+	Subsets []string `json:"subsets,omitempty" query:"subsets"`
+	Search  *string  `json:"search,omitempty" query:"search,default:nil"`
+
+	Sort  FontSort      `json:"sort,omitempty" query:"sort"`
+	Order SortDirection `json:"order,omitempty" query:"order"`
 }
 
 func (f *GetFontsFilters) HasCategory() bool { return len(f.Categories) > 0 }
-func (f *GetFontsFilters) HasSearch() bool   { return f.Search != nil && *f.Search != "" }
+func (f *GetFontsFilters) HasSubsets() bool  { return len(f.Subsets) > 0 }
+func (f *GetFontsFilters) HasSearch() bool   { return f != nil && f.Search != nil && *f.Search != "" }
 
 func (f *GetFontsFilters) CanAddToResults(font *FontFamilyData) bool {
 	if f.HasCategory() && !slices.Contains(f.Categories, font.Category) {
 		return false
 	}
-	if f.HasSearch() && !strings.Contains(strings.ToLower(font.Name), strings.ToLower(*f.Search)) {
+	if f.HasSubsets() && !font.SupportsSubsets(f.Subsets) {
+		return false
+	}
+	if f.HasSearch() && f.SearchScore(font) == 0 {
 		return false
 	}
 	return true
 }
+
+// Apply filters and sorts the fonts, setting the SearchScore of each result
+// when searching. f may be nil, in which case every font is returned.
+func (f *GetFontsFilters) Apply(fonts iter.Seq[FontFamilyData]) []FontFamilyData {
+	items := make([]FontFamilyData, 0)
+	for font := range fonts {
+		if f != nil && !f.CanAddToResults(&font) {
+			continue
+		}
+		if f.HasSearch() {
+			font.SearchScore = f.SearchScore(&font)
+		}
+		items = append(items, font)
+	}
+
+	slices.SortFunc(items, f.Compare)
+
+	return items
+}
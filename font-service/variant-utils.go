@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 func sortVariants(variants []FontFamilyVariant) []FontFamilyVariant {
@@ -31,6 +33,64 @@ func sortVariants(variants []FontFamilyVariant) []FontFamilyVariant {
 	return append(append(regularVariants, nonRegularVariants...), italicVariants...)
 }
 
+// createVariantPreviewObj builds the preview urls of a variant. The family
+// name is the preview text of latin families, CJK families and families
+// without latin leave the text out so their previews show a sample text the
+// font has glyphs for, see defaultPreviewText.
+func createVariantPreviewObj(providerId, family, variantName string, subsets []string) VariantPreviewObject {
+	variantStr := strings.ReplaceAll(family, " ", "%20") + ":" + variantName
+
+	textParam := ""
+	if showsFamilyName(subsets) {
+		textParam = "&text=" + strings.ReplaceAll(family, " ", "%20")
+	}
+
+	return VariantPreviewObject{
+		Template: fmt.Sprintf("/api/%s/fonts/preview?families=%s&resultType=png&small={IsSmall}&text={Text}&color={Color}&background={Background}&padding={Padding}&align={Align}", providerId, variantStr),
+		Small:    fmt.Sprintf("/api/%s/fonts/preview?families=%s&resultType=png&small=true%s", providerId, variantStr, textParam),
+		Large:    fmt.Sprintf("/api/%s/fonts/preview?families=%s&resultType=png&small=false%s", providerId, variantStr, textParam),
+	}
+}
+
+// createFontFileURL is the url of the file endpoint serving the variant's
+// file, or the variable font file it's an instance of
+func createFontFileURL(providerId, family, variantName string, variable bool) string {
+	url := fmt.Sprintf("/api/%s/fonts/file?family=%s:%s", providerId, strings.ReplaceAll(family, " ", "%20"), variantName)
+	if variable {
+		url += "&variable=true"
+	}
+	return url
+}
+
+// variantNameForWeight builds a variant name in the same format the Google
+// Fonts API uses, e.g. "regular", "italic", "700" and "700italic".
+func variantNameForWeight(weight int, italic bool) string {
+	if weight == 400 {
+		if italic {
+			return "italic"
+		}
+		return "regular"
+	}
+
+	if italic {
+		return fmt.Sprintf("%ditalic", weight)
+	}
+	return fmt.Sprintf("%d", weight)
+}
+
+// variantWeightAndStyle is the reverse of variantNameForWeight, names it
+// doesn't know are treated as regular
+func variantWeightAndStyle(name string) (int, bool) {
+	italic := strings.HasSuffix(name, "italic")
+
+	weight, err := strconv.Atoi(strings.TrimSuffix(name, "italic"))
+	if err != nil {
+		weight = 400
+	}
+
+	return weight, italic
+}
+
 func extractNumFromVariant(name string) int {
 	var num int
 	_, err := fmt.Sscanf(name, "%ditalic", &num)
@@ -4,8 +4,8 @@ import (
 	"bytes"
 	b64 "encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -14,6 +14,9 @@ import (
 	"GoogleFontsPluginApi/logger"
 )
 
+// headerFontCoverage is the percentage of the preview text the font covers
+const headerFontCoverage = "X-Font-Coverage"
+
 type FontsApi struct {
 	Group fiber.Router
 }
@@ -23,23 +26,13 @@ func NewFontsApi(app *fiber.App, api fiber.Router) *FontsApi {
 		Group: api.Group("/:provider/fonts"),
 	}
 
-	/*inst.Group.Use(cache.New(cache.Config{
-		ExpirationGenerator: func(c fiber.Ctx, cfg *cache.Config) time.Duration {
-			newCacheTime, _ := strconv.Atoi(c.GetRespHeader("Cache-Time", "600"))
-			return time.Second * time.Duration(newCacheTime)
-		},
-		KeyGenerator: func(c fiber.Ctx) string {
-			return utils.CopyString(c.Path())
-		},
-	}))*/
-
 	inst.Group.Use(func(c fiber.Ctx) error {
 		providerId := fiber.Params[string](c, "provider")
 		fiber.Locals[string](c, "providerId", providerId)
 
 		p := font_service.GetFontProvider(providerId)
 		if p == nil {
-			return fiber.ErrNotFound
+			return NewNotFoundError("provider_not_found", "provider %s not found", providerId)
 		}
 
 		fiber.Locals[font_service.IFontProvider](c, "provider", p)
@@ -48,9 +41,12 @@ func NewFontsApi(app *fiber.App, api fiber.Router) *FontsApi {
 	})
 
 	inst.Group.Get("/all", inst.All)
+	inst.Group.Get("/changes", inst.Changes)
 	inst.Group.Get("/preview", inst.Preview)
 	inst.Group.Get("/preview/multi", inst.PreviewMulti)
+	inst.Group.Get("/coverage", inst.Coverage)
 	inst.Group.Get("/license/:family", inst.License)
+	inst.Group.Get("/file", inst.File)
 
 	return inst
 }
@@ -62,6 +58,15 @@ func (a *FontsApi) All(c fiber.Ctx) error {
 
 	opts := new(font_service.GetFontsFilters)
 	if err := c.Bind().Query(opts); err != nil {
+		return NewBadRequestError("invalid_query", "%v", err)
+	}
+
+	pageOpts := new(font_service.FontsPageOptions)
+	if err := c.Bind().Query(pageOpts); err != nil {
+		return NewBadRequestError("invalid_query", "%v", err)
+	}
+
+	if err := opts.Validate(); err != nil {
 		return err
 	}
 
@@ -72,48 +77,220 @@ func (a *FontsApi) All(c fiber.Ctx) error {
 		return err
 	}
 
+	page, err := font_service.PaginateFonts(all, pageOpts, opts.SortKey())
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(map[string]any{
-		"items": all,
+		"items":      page.Items,
+		"total":      page.Total,
+		"offset":     page.Offset,
+		"limit":      page.Limit,
+		"nextCursor": page.NextCursor,
+		"facets":     font_service.GetFontFacets(provider, opts),
 	})
 }
 
+func (a *FontsApi) Changes(c fiber.Ctx) error {
+	provider := font_service.GetFontProviderFromCtx(c)
+
+	sinceStr := fiber.Query[string](c, "since")
+	if sinceStr == "" {
+		return NewBadRequestError("missing_parameter", "since is required")
+	}
+
+	since, err := parseTimestamp(sinceStr)
+	if err != nil {
+		return NewBadRequestError("invalid_parameter", "%v", err)
+	}
+
+	changes, err := font_service.GetCatalogChangesSince(provider.GetId(), since)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(changes)
+}
+
+// parseTimestamp accepts RFC3339 timestamps, plain dates (2006-01-02) and unix seconds
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp %q, expected RFC3339, YYYY-MM-DD or unix seconds", value)
+}
+
+// etagMatches reports whether the If-None-Match header matches the etag, using
+// the weak comparison If-None-Match calls for
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiatePreviewFormat picks the image format from the Accept header when
+// a raw preview doesn't ask for one. Clients that accept anything get png,
+// clients that list webp or jpeg, like browsers loading an image, get that.
+// Previews with a quality are jpeg.
+func negotiatePreviewFormat(c fiber.Ctx, r *font_service.CreateFontPreviewOptions) {
+	if r.Format != "" {
+		return
+	}
+
+	switch r.ResultType {
+	case "", font_service.FontPreviewResultTypeRaw, font_service.FontPreviewResultTypePng:
+	default:
+		return
+	}
+
+	// Only jpeg takes a quality, so previews asking for one don't depend on the Accept header
+	if r.Quality != 0 {
+		r.Format = font_service.PreviewFormatJpeg
+		return
+	}
+
+	c.Vary(fiber.HeaderAccept)
+
+	offers := make([]string, len(font_service.PreviewFormatContentTypes))
+	for i, f := range font_service.PreviewFormatContentTypes {
+		offers[i] = f.ContentType
+	}
+
+	accepted := c.Accepts(offers...)
+	for _, f := range font_service.PreviewFormatContentTypes {
+		if f.ContentType == accepted {
+			r.Format = f.Format
+		}
+	}
+}
+
 func (a *FontsApi) Preview(c fiber.Ctx) error {
 	provider := font_service.GetFontProviderFromCtx(c)
 
 	r := new(font_service.CreateFontPreviewOptions)
 	if err := c.Bind().Query(r); err != nil {
+		return NewBadRequestError("invalid_query", "%v", err)
+	}
+
+	negotiatePreviewFormat(c, r)
+
+	if err := r.Validate(); err != nil {
 		return err
 	}
 
+	if r.ResultType == font_service.FontPreviewResultTypeSprite {
+		return NewBadRequestError("invalid_result_type", "result type sprite is only supported for multi preview")
+	}
+
 	familyData, err := r.FirstFamilyAndVariant()
 	if err != nil {
 		return err
 	}
 
-	dctx, err := font_service.CreateFontPreview(provider, r, familyData)
+	preview, err := font_service.PrepareFontPreview(provider, r, familyData)
 	if err != nil {
 		return err
 	}
 
-	// We need to encode as a png to a temporary buffer
-	// so we can either output it as a base64 string or directly to the response
-	var buf bytes.Buffer
-	if err := dctx.EncodePNG(&buf); err != nil {
+	// The etag is known before rendering, so revalidations never render anything
+	etag := preview.ETag()
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		c.Set(fiber.HeaderETag, etag)
+		c.Set(fiber.HeaderCacheControl, font_service.GetPreviewCacheControl())
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	rendered, err := preview.Render()
+	if err != nil {
 		return err
 	}
+	data := rendered.Data
+
+	// Only set after rendering, errors shouldn't be cached
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, font_service.GetPreviewCacheControl())
 
-	// If the result type is base64, we just send the base64 string
-	// otherwise we set the content type to image/png and send the image
-	if r.ResultType == font_service.FontPreviewResultTypeBase64 {
-		base64Str := b64.StdEncoding.EncodeToString(buf.Bytes())
+	// The percentage of the text's runes the font has glyphs for, missing ones
+	// are drawn as .notdef boxes. /fonts/coverage lists which ones are missing.
+	c.Set(headerFontCoverage, strconv.FormatFloat(rendered.Coverage.Percentage, 'f', -1, 64))
+
+	// Base64 results are the encoded image as a string, the other result types
+	// are sent as the image itself
+	switch r.ResultType {
+	case font_service.FontPreviewResultTypeBase64:
 		c.Set("Content-Type", "text/plain")
-		return c.SendString(base64Str)
-	} else if r.ResultType == font_service.FontPreviewResultTypePng {
-		c.Set("Content-Type", "image/png")
-		return c.SendStream(&buf)
+		return c.SendString(b64.StdEncoding.EncodeToString(data))
+	case font_service.FontPreviewResultTypeRaw:
+		c.Set("Content-Type", r.Format.ContentType())
+		return c.Send(data)
+	case font_service.FontPreviewResultTypeSvg:
+		c.Set("Content-Type", "image/svg+xml")
+		return c.Send(data)
 	}
 
-	return fmt.Errorf("unknown result type")
+	return NewBadRequestError("invalid_result_type", "unknown result type %q", r.ResultType)
+}
+
+// fontCoverageResult is the coverage of a family, or the reason it couldn't be checked
+type fontCoverageResult struct {
+	*font_service.FontCoverage
+	Error *ApiError `json:"error,omitempty"`
+}
+
+// Coverage reports which runes of the text every family is missing, so
+// clients can warn about fonts that can't render it before they're picked
+func (a *FontsApi) Coverage(c fiber.Ctx) error {
+	provider := font_service.GetFontProviderFromCtx(c)
+
+	r := new(font_service.FontCoverageOptions)
+	if err := c.Bind().Query(r); err != nil {
+		return NewBadRequestError("invalid_query", "%v", err)
+	}
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	// Family name -> coverage or the reason it couldn't be checked
+	results := map[string]fontCoverageResult{}
+	for _, result := range font_service.GetFontsCoverage(provider, r) {
+		if result.Err != nil {
+			apiErr := ToApiError(result.Err)
+			if apiErr.Status >= fiber.StatusInternalServerError {
+				logger.Error("Failed to check the coverage of %s: %v", result.Family.FullName(), result.Err)
+			}
+			results[result.Family.FullName()] = fontCoverageResult{Error: apiErr}
+			continue
+		}
+
+		results[result.Family.FullName()] = fontCoverageResult{FontCoverage: &result.Coverage}
+	}
+
+	return c.JSON(results)
+}
+
+type previewMultiResult struct {
+	// Base64 encoded image or svg markup, empty when the preview failed
+	Image string    `json:"image"`
+	Error *ApiError `json:"error"`
 }
 
 func (a *FontsApi) PreviewMulti(c fiber.Ctx) error {
@@ -121,49 +298,95 @@ func (a *FontsApi) PreviewMulti(c fiber.Ctx) error {
 
 	r := new(font_service.CreateFontPreviewOptions)
 	if err := c.Bind().Query(r); err != nil {
-		return err
+		return NewBadRequestError("invalid_query", "%v", err)
 	}
 
-	if r.ResultType == font_service.FontPreviewResultTypePng {
-		return fmt.Errorf("result type png not supported for multi preview")
+	if r.ResultType == font_service.FontPreviewResultTypeSprite {
+		return a.previewSprite(c, provider, r)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(r.Families))
+	// Checked before validating, which turns png into raw
+	if r.ResultType == font_service.FontPreviewResultTypeRaw || r.ResultType == font_service.FontPreviewResultTypePng {
+		return NewBadRequestError("invalid_result_type", "result type %s not supported for multi preview", r.ResultType)
+	}
 
-	// Family name -> base64 encoded image
-	results := map[string]string{}
-	familiesData := r.FamilyAndVariants()
-	// Prepare the map
-	for _, familyData := range familiesData {
-		results[familyData.FullName()] = ""
+	if err := r.ValidateMulti(); err != nil {
+		return err
 	}
-	for _, familyData := range familiesData {
-		go func(familyData font_service.FontAndVariant) {
-			defer wg.Done()
 
-			dctx, err := font_service.CreateFontPreview(provider, r, familyData)
-			if err != nil {
-				fmt.Println("Error creating font preview:", err)
-				return
+	// Family name -> preview or the reason it failed
+	results := map[string]previewMultiResult{}
+	for _, preview := range font_service.RenderFontPreviews(provider, r) {
+		if preview.Err != nil {
+			apiErr := ToApiError(preview.Err)
+			if apiErr.Status >= fiber.StatusInternalServerError {
+				logger.Error("Failed to create preview for %s: %v", preview.Family.FullName(), preview.Err)
 			}
+			results[preview.Family.FullName()] = previewMultiResult{Error: apiErr}
+			continue
+		}
 
-			// We need to encode as a png to a temporary buffer
-			// so we can either output it as a base64 string or directly to the response
-			var buf bytes.Buffer
-			if err := dctx.EncodePNG(&buf); err != nil {
-				fmt.Println("Error encoding PNG:", err)
-				return
-			}
+		// Svg markup is sent as is, images as base64
+		image := string(preview.Data)
+		if r.ResultType != font_service.FontPreviewResultTypeSvg {
+			image = b64.StdEncoding.EncodeToString(preview.Data)
+		}
+		results[preview.Family.FullName()] = previewMultiResult{Image: image}
+	}
+
+	return c.JSON(results)
+}
+
+// Failed families have no cell in the sprite, their position is all zeroes
+type previewSpriteEntry struct {
+	X      int       `json:"x"`
+	Y      int       `json:"y"`
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Error  *ApiError `json:"error"`
+}
 
-			base64Str := b64.StdEncoding.EncodeToString(buf.Bytes())
-			results[familyData.FullName()] = base64Str
-		}(familyData)
+// previewSprite responds with the sprite as base64 along with a manifest of
+// where each family is in it. Positions are in pixels of the sprite image,
+// divide them by the scale for css pixels.
+func (a *FontsApi) previewSprite(c fiber.Ctx, provider font_service.IFontProvider, r *font_service.CreateFontPreviewOptions) error {
+	if err := r.ValidateSprite(); err != nil {
+		return err
+	}
+
+	sprite, err := font_service.RenderFontPreviewSprite(provider, r)
+	if err != nil {
+		return err
 	}
 
-	wg.Wait()
+	// Family name -> position in the sprite or the reason it failed
+	families := make(map[string]previewSpriteEntry, len(sprite.Entries))
+	for family, entry := range sprite.Entries {
+		if entry.Error != nil {
+			apiErr := ToApiError(entry.Error)
+			if apiErr.Status >= fiber.StatusInternalServerError {
+				logger.Error("Failed to create preview for %s: %v", family, entry.Error)
+			}
+			families[family] = previewSpriteEntry{Error: apiErr}
+			continue
+		}
 
-	return c.JSON(results)
+		families[family] = previewSpriteEntry{
+			X:      entry.X,
+			Y:      entry.Y,
+			Width:  entry.Width,
+			Height: entry.Height,
+		}
+	}
+
+	return c.JSON(map[string]any{
+		"image":       b64.StdEncoding.EncodeToString(sprite.Image),
+		"contentType": sprite.Format.ContentType(),
+		"width":       sprite.Width,
+		"height":      sprite.Height,
+		"scale":       sprite.Scale,
+		"families":    families,
+	})
 }
 
 func (a *FontsApi) License(c fiber.Ctx) error {
@@ -174,11 +397,11 @@ func (a *FontsApi) License(c fiber.Ctx) error {
 
 	font, found := provider.GetFontCache().Get(family)
 	if !found {
-		return fiber.ErrNotFound
+		return NewNotFoundError(font_service.ErrFontNotFound.Code, "font family %s not found", family)
 	}
 
 	if !font.HasLicense {
-		return fiber.ErrNotFound
+		return NewNotFoundError("license_not_found", "font family %s has no license", family)
 	}
 
 	content, err := font.GetLicenseContent(provider)
@@ -188,3 +411,42 @@ func (a *FontsApi) License(c fiber.Ctx) error {
 
 	return c.SendString(content)
 }
+
+// File sends the font file of a variant, e.g. ?family=Inter:700. With
+// variable=true it sends the variable font the variant is an instance of.
+func (a *FontsApi) File(c fiber.Ctx) error {
+	provider := font_service.GetFontProviderFromCtx(c)
+
+	familyStr := fiber.Query[string](c, "family")
+	if familyStr == "" {
+		return NewBadRequestError("missing_parameter", "family is required")
+	}
+
+	family := font_service.ExtractFamilyAndVariant(familyStr)
+	data, err := provider.GetFontAndVariant(family.Family, family.Variant)
+	if err != nil {
+		return err
+	}
+
+	fontData, err := font_service.GetFontFile(provider, data, fiber.Query[bool](c, "variable"))
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, fontContentType(fontData))
+	return c.Send(fontData)
+}
+
+// fontContentType detects the format of a font file from its signature
+func fontContentType(fontData []byte) string {
+	switch {
+	case bytes.HasPrefix(fontData, []byte("OTTO")):
+		return "font/otf"
+	case bytes.HasPrefix(fontData, []byte("wOF2")):
+		return "font/woff2"
+	case bytes.HasPrefix(fontData, []byte("wOFF")):
+		return "font/woff"
+	default:
+		return "font/ttf"
+	}
+}
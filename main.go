@@ -23,10 +23,12 @@ func main() {
 		panic(err)
 	}
 	logger.Init(conf.Config)
+	fontservice.InitProviders()
 
 	app := fiber.New(fiber.Config{
-		JSONEncoder: json.Marshal,
-		JSONDecoder: json.Unmarshal,
+		JSONEncoder:  json.Marshal,
+		JSONDecoder:  json.Unmarshal,
+		ErrorHandler: ErrorHandler,
 	})
 	app.Use(recover2.New(recover2.Config{
 		EnableStackTrace: true,
@@ -49,6 +51,7 @@ func main() {
 				"displayName": provider.GetDisplayName(),
 				"endpoint":    provider.GetEndpoint(),
 				"categories":  provider.GetCategories(),
+				"lastDiff":    provider.GetLastCatalogDiff(),
 			})
 		}
 		return c.JSON(map[string]interface{}{
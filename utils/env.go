@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"GoogleFontsPluginApi/logger"
+)
+
+// GetEnvInt reads an integer environment variable, returning fallback when
+// it's unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warning("Invalid %s %q, using %v: %v", key, value, fallback, err)
+		return fallback
+	}
+
+	return v
+}
+
+// GetEnvDuration reads a duration environment variable such as "6h" or "30s",
+// returning fallback when it's unset or invalid
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warning("Invalid %s %q, using %v: %v", key, value, fallback, err)
+		return fallback
+	}
+
+	return v
+}